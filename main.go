@@ -1,15 +1,39 @@
 package main
 
 import (
+	"api/src/audit"
+	"api/src/authentication"
 	"api/src/config"
+	"api/src/jobs"
+	"api/src/password"
 	"api/src/router"
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 )
 
 func main() {
 	config.Config()
+	if err := authentication.LoadKeys(); err != nil {
+		log.Fatal(err)
+	}
+	if err := password.Load(); err != nil {
+		log.Fatal(err)
+	}
+	go authentication.RotateKeys(time.Minute)
+	go audit.Retention(time.Hour)
+	jobs.Start(2, 10*time.Minute)
+	jobs.RequeueExports()
+	go jobs.WatchExports(10 * time.Minute)
+	go jobs.PurgeAccounts(time.Hour)
+	go jobs.CleanupExports(time.Hour)
+	go jobs.CleanupOAuth(time.Hour)
+	go jobs.CleanupLoginAttempts(time.Hour)
+	go jobs.CleanupRateLimits(time.Hour)
+	go jobs.RefreshSuggestions(10 * time.Minute)
+	go jobs.ReconcileCounters(6 * time.Hour)
+
 	r := router.Create()
 	fmt.Println("Listen on port 3000")
 	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", config.Port), r))
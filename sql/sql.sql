@@ -8,8 +8,12 @@ CREATE TABLE users(
     name varchar(55) NOT NULL,
     nick varchar(55) NOT NULL unique,
     email varchar(55) NOT NULL unique,
-    password varchar(100) NOT NULL,
-    createAt timestamp default current_timestamp()
+    password varchar(255) NOT NULL,
+    createAt timestamp default current_timestamp(),
+    deactivatedAt timestamp NULL,
+    dmFollowingOnly boolean NOT NULL default false,
+    follower_count int NOT NULL default 0,
+    following_count int NOT NULL default 0
 );
 
 CREATE TABLE followers(
@@ -19,8 +23,290 @@ CREATE TABLE followers(
     ON DELETE CASCADE,
 
     follower_id int NOT NULL,
-    FOREIGN KEY (user_id)
+    FOREIGN KEY (follower_id)
     REFERENCES users(id)
     ON DELETE CASCADE,
     primary key(user_id, follower_id)
-);
\ No newline at end of file
+);
+
+CREATE TABLE login_attempts(
+    attempt_key varchar(120) primary key,
+    failures int NOT NULL default 0,
+    last_failure timestamp NULL,
+    locked_until timestamp NULL
+);
+
+CREATE TABLE rate_limits(
+    limit_key varchar(255) NOT NULL,
+    window_start datetime NOT NULL,
+    hits int NOT NULL default 0,
+    primary key(limit_key, window_start)
+);
+
+CREATE TABLE access_tokens(
+    id int auto_increment primary key,
+    user_id int NOT NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    name varchar(55) NOT NULL,
+    token_hash char(64) NOT NULL unique,
+    scopes varchar(255) NOT NULL,
+    expires_at timestamp NULL,
+    last_used_at timestamp NULL,
+    createAt timestamp default current_timestamp()
+);
+
+CREATE TABLE sessions(
+    id char(32) primary key,
+    user_id int NOT NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    user_agent varchar(255) NOT NULL,
+    ip varchar(45) NOT NULL,
+    createAt timestamp default current_timestamp(),
+    last_seen_at timestamp NOT NULL,
+    revoked_at timestamp NULL
+);
+
+CREATE TABLE user_identities(
+    issuer varchar(255) NOT NULL,
+    subject varchar(255) NOT NULL,
+    user_id int NOT NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+    createAt timestamp default current_timestamp(),
+    primary key(issuer, subject)
+);
+
+CREATE TABLE oauth_clients(
+    id char(32) primary key,
+    secret_hash char(64) NOT NULL,
+    owner_id int NOT NULL,
+    FOREIGN KEY (owner_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    name varchar(55) NOT NULL,
+    redirect_uris text NOT NULL,
+    scopes varchar(255) NOT NULL,
+    confidential boolean NOT NULL default true,
+    createAt timestamp default current_timestamp()
+);
+
+CREATE TABLE oauth_codes(
+    code_hash char(64) primary key,
+    client_id char(32) NOT NULL,
+    FOREIGN KEY (client_id)
+    REFERENCES oauth_clients(id)
+    ON DELETE CASCADE,
+
+    user_id int NOT NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    redirect_uri text NOT NULL,
+    scopes varchar(255) NOT NULL,
+    code_challenge varchar(128) NOT NULL,
+    expires_at timestamp NOT NULL
+);
+
+CREATE TABLE oauth_tokens(
+    token_hash char(64) primary key,
+    client_id char(32) NOT NULL,
+    FOREIGN KEY (client_id)
+    REFERENCES oauth_clients(id)
+    ON DELETE CASCADE,
+
+    user_id int NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    scopes varchar(255) NOT NULL,
+    expires_at timestamp NOT NULL,
+    revoked_at timestamp NULL,
+    createAt timestamp default current_timestamp()
+);
+
+CREATE TABLE audit_log(
+    id bigint auto_increment primary key,
+    actor_id int NULL,
+    action varchar(55) NOT NULL,
+    target_type varchar(55) NOT NULL default '',
+    target_id varchar(255) NOT NULL default '',
+    ip varchar(45) NOT NULL,
+    user_agent varchar(255) NOT NULL,
+    changes text NULL,
+    createAt timestamp default current_timestamp(),
+    INDEX (actor_id),
+    INDEX (action),
+    INDEX (target_type, target_id),
+    INDEX (createAt)
+);
+
+CREATE TABLE notifications(
+    id bigint auto_increment primary key,
+    user_id int NOT NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    kind varchar(55) NOT NULL,
+    message varchar(255) NOT NULL,
+    link varchar(512) NOT NULL default '',
+    read_at timestamp NULL,
+    createAt timestamp default current_timestamp()
+);
+
+CREATE TABLE data_exports(
+    id char(32) primary key,
+    user_id int NOT NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    status varchar(20) NOT NULL,
+    error varchar(255) NOT NULL default '',
+    file_key varchar(255) NOT NULL default '',
+    expires_at timestamp NULL,
+    createAt timestamp default current_timestamp(),
+    completed_at timestamp NULL,
+    -- the instance building a pending export, until its lease lapses
+    claimed_by char(32) NOT NULL default '',
+    lease_until timestamp NULL
+);
+
+
+CREATE TABLE conversations(
+    id bigint auto_increment primary key,
+    createAt timestamp default current_timestamp(),
+    updatedAt timestamp default current_timestamp()
+);
+
+CREATE TABLE conversation_members(
+    conversation_id bigint NOT NULL,
+    FOREIGN KEY (conversation_id)
+    REFERENCES conversations(id)
+    ON DELETE CASCADE,
+
+    user_id int NOT NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    last_read_id bigint NOT NULL default 0,
+    primary key(conversation_id, user_id),
+    INDEX (user_id)
+);
+
+CREATE TABLE messages(
+    id bigint auto_increment primary key,
+    conversation_id bigint NOT NULL,
+    FOREIGN KEY (conversation_id)
+    REFERENCES conversations(id)
+    ON DELETE CASCADE,
+
+    sender_id int NOT NULL,
+    FOREIGN KEY (sender_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    body text NOT NULL,
+    createAt timestamp default current_timestamp(),
+    INDEX (conversation_id, id)
+);
+
+CREATE TABLE message_deletions(
+    message_id bigint NOT NULL,
+    FOREIGN KEY (message_id)
+    REFERENCES messages(id)
+    ON DELETE CASCADE,
+
+    user_id int NOT NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    primary key(message_id, user_id)
+);
+
+CREATE TABLE follow_suggestions(
+    user_id int NOT NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    candidate_id int NOT NULL,
+    FOREIGN KEY (candidate_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    score double NOT NULL,
+    mutuals int NOT NULL,
+    follows_you boolean NOT NULL,
+    computedAt timestamp default current_timestamp(),
+    primary key(user_id, candidate_id),
+    INDEX (computedAt)
+);
+
+CREATE TABLE follow_suggestion_runs(
+    user_id int primary key,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    computedAt timestamp NOT NULL,
+    INDEX (computedAt)
+);
+
+CREATE TABLE lists(
+    id bigint auto_increment primary key,
+    owner_id int NOT NULL,
+    FOREIGN KEY (owner_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    name varchar(55) NOT NULL,
+    description varchar(255) NOT NULL default '',
+    private boolean NOT NULL default false,
+    member_count int NOT NULL default 0,
+    subscriber_count int NOT NULL default 0,
+    createAt timestamp default current_timestamp()
+);
+
+CREATE TABLE list_members(
+    list_id bigint NOT NULL,
+    FOREIGN KEY (list_id)
+    REFERENCES lists(id)
+    ON DELETE CASCADE,
+
+    user_id int NOT NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    createAt timestamp default current_timestamp(),
+    primary key(list_id, user_id)
+);
+
+CREATE TABLE list_subscriptions(
+    list_id bigint NOT NULL,
+    FOREIGN KEY (list_id)
+    REFERENCES lists(id)
+    ON DELETE CASCADE,
+
+    user_id int NOT NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+
+    createAt timestamp default current_timestamp(),
+    primary key(list_id, user_id),
+    INDEX (user_id)
+);
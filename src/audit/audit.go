@@ -0,0 +1,100 @@
+package audit
+
+import (
+	"api/src/authentication"
+	"api/src/config"
+	"api/src/database"
+	"api/src/models"
+	"api/src/repository"
+	"api/src/utils"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Actions written to the audit log
+const (
+	Login          = "login"
+	LoginFailed    = "login_failed"
+	LoginLocked    = "login_locked"
+	LoginUnlocked  = "login_unlocked"
+	Logout         = "logout"
+	UserCreated    = "user_created"
+	UserUpdated    = "user_updated"
+	UserDeleted    = "user_deleted"
+	UserRestored   = "user_restored"
+	PasswordChange = "password_changed"
+	Follow         = "follow"
+	Unfollow       = "unfollow"
+	SessionRevoked = "session_revoked"
+	TokenCreated   = "token_created"
+	TokenRevoked   = "token_revoked"
+
+	OAuthClientCreate = "oauth_client_created"
+	OAuthClientDelete = "oauth_client_deleted"
+	OAuthConsent      = "oauth_consent"
+	OAuthTokenIssued  = "oauth_token_issued"
+	OAuthRevoke       = "oauth_token_revoked"
+)
+
+// Record - append entry for the request, filling the actor from its token
+// and the caller's IP and user agent. A failure to write is logged, it
+// doesn't fail the audited action.
+func Record(r *http.Request, db *sql.DB, entry models.AuditEntry) {
+	if entry.ActorID == 0 {
+		entry.ActorID, _ = authentication.GetUserID(r)
+	}
+	entry.IP = utils.ClientIP(r)
+	entry.UserAgent = r.UserAgent()
+	if len(entry.UserAgent) > 255 {
+		entry.UserAgent = entry.UserAgent[:255]
+	}
+	if err := repository.NewAuditRepo(db).Create(r.Context(), entry); err != nil {
+		log.Printf("audit %s: %v", entry.Action, err)
+	}
+}
+
+// UserChanges - fields of user that an update changed
+func UserChanges(before models.User, after models.User) json.RawMessage {
+	changes := map[string]models.Change{}
+	if before.Name != after.Name {
+		changes["name"] = models.Change{Before: before.Name, After: after.Name}
+	}
+	if before.Nick != after.Nick {
+		changes["nick"] = models.Change{Before: before.Nick, After: after.Nick}
+	}
+	if before.Email != after.Email {
+		changes["email"] = models.Change{Before: before.Email, After: after.Email}
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// Retention - purge entries older than AUDIT_RETENTION every interval
+func Retention(interval time.Duration) {
+	if config.AuditRetention <= 0 {
+		return
+	}
+	for range time.Tick(interval) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		db, err := database.Connect(ctx)
+		if err == nil {
+			var purged int64
+			purged, err = repository.NewAuditRepo(db).Purge(ctx, time.Now().Add(-config.AuditRetention))
+			if purged > 0 {
+				log.Printf("audit: purged %d entries past retention", purged)
+			}
+			db.Close()
+		}
+		cancel()
+		if err != nil {
+			log.Printf("audit retention: %v", err)
+		}
+	}
+}
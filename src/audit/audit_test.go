@@ -0,0 +1,37 @@
+package audit
+
+import (
+	"api/src/models"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestUserChanges(t *testing.T) {
+	before := models.User{Name: "Name", Nick: "nick", Email: "nick@example.com", Password: "hash"}
+	tests := []struct {
+		name  string
+		after models.User
+		want  map[string]models.Change
+	}{
+		{"unchanged", before, map[string]models.Change{}},
+		{"name", models.User{Name: "Other", Nick: "nick", Email: "nick@example.com"}, map[string]models.Change{
+			"name": {Before: "Name", After: "Other"},
+		}},
+		{"nick and email", models.User{Name: "Name", Nick: "other", Email: "other@example.com"}, map[string]models.Change{
+			"nick":  {Before: "nick", After: "other"},
+			"email": {Before: "nick@example.com", After: "other@example.com"},
+		}},
+		// the password hash never ends up in the log
+		{"password", models.User{Name: "Name", Nick: "nick", Email: "nick@example.com", Password: "other"}, map[string]models.Change{}},
+	}
+	for _, test := range tests {
+		var got map[string]models.Change
+		if err := json.Unmarshal(UserChanges(before, test.after), &got); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: UserChanges = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
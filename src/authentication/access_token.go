@@ -0,0 +1,160 @@
+package authentication
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"api/src/repository"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// AccessTokenPrefix - marks personal access tokens apart from JWTs
+	AccessTokenPrefix = "pat_"
+	// OAuthTokenPrefix - marks access tokens issued to OAuth2 clients
+	OAuthTokenPrefix = "oat_"
+)
+
+// NewAccessToken - generate a personal access token and the hash to store
+func NewAccessToken() (token string, hash string, err error) {
+	return NewSecret(AccessTokenPrefix)
+}
+
+// NewOAuthToken - generate an OAuth2 access token and the hash to store
+func NewOAuthToken() (token string, hash string, err error) {
+	return NewSecret(OAuthTokenPrefix)
+}
+
+// NewSecret - 256 random bits after prefix, and the hash to store
+func NewSecret(prefix string) (secret string, hash string, err error) {
+	random := make([]byte, 32)
+	if _, err = rand.Read(random); err != nil {
+		return "", "", err
+	}
+	secret = prefix + base64.RawURLEncoding.EncodeToString(random)
+	return secret, HashAccessToken(secret), nil
+}
+
+// HashAccessToken - tokens are random, a plain SHA-256 is enough to store them
+func HashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func isAccessToken(token string) bool {
+	return strings.HasPrefix(token, AccessTokenPrefix)
+}
+
+func isOAuthToken(token string) bool {
+	return strings.HasPrefix(token, OAuthTokenPrefix)
+}
+
+// verifyAccessToken - find an unexpired access token and record its use
+func verifyAccessToken(ctx context.Context, tokenString string) (models.AccessToken, error) {
+	db, err := database.Connect(ctx)
+	if err != nil {
+		return models.AccessToken{}, err
+	}
+	defer db.Close()
+
+	tokenRepo := repository.NewAccessTokenRepo(db)
+	token, err := tokenRepo.FindByHash(ctx, HashAccessToken(tokenString))
+	if err != nil {
+		return models.AccessToken{}, err
+	}
+	now := time.Now()
+	if token.ID == 0 || token.Expired(now) {
+		return models.AccessToken{}, errors.New("Token invalid")
+	}
+	if err = tokenRepo.Touch(ctx, token.ID, now); err != nil {
+		log.Printf("access token %d last use: %v", token.ID, err)
+	}
+	until := now.Add(verifiedFor)
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(until) {
+		until = *token.ExpiresAt
+	}
+	rememberVerified(HashAccessToken(tokenString), "user:"+strconv.FormatUint(token.UserID, 10), until)
+	return token, nil
+}
+
+// verifyOAuthToken - find an active token issued to an OAuth2 client
+func verifyOAuthToken(ctx context.Context, tokenString string) (models.OAuthToken, error) {
+	db, err := database.Connect(ctx)
+	if err != nil {
+		return models.OAuthToken{}, err
+	}
+	defer db.Close()
+
+	token, err := repository.NewOAuthRepo(db).FindToken(ctx, HashAccessToken(tokenString))
+	if err != nil {
+		return models.OAuthToken{}, err
+	}
+	now := time.Now()
+	if !token.Active(now) {
+		return models.OAuthToken{}, errors.New("Token invalid")
+	}
+	key := "client:" + token.ClientID
+	if token.UserID != 0 {
+		key = "user:" + strconv.FormatUint(token.UserID, 10)
+	}
+	until := now.Add(verifiedFor)
+	if token.ExpiresAt.Before(until) {
+		until = token.ExpiresAt
+	}
+	rememberVerified(HashAccessToken(tokenString), key, until)
+	return token, nil
+}
+
+// verifiedFor - how long a verified token keeps its own rate limit bucket
+// before it has to be looked up again; a revoked token keeps it at most
+// this long, authentication itself always checks the database
+const verifiedFor = 5 * time.Minute
+
+// verified - rate limit keys of access tokens that resolved to a live row,
+// by token hash, so made-up tokens stay in the caller's IP bucket
+var verified = struct {
+	sync.Mutex
+	keys      map[string]verifiedKey
+	lastPrune time.Time
+}{keys: map[string]verifiedKey{}}
+
+type verifiedKey struct {
+	key   string
+	until time.Time
+}
+
+func rememberVerified(hash string, key string, until time.Time) {
+	verified.Lock()
+	defer verified.Unlock()
+	now := time.Now()
+	if now.Sub(verified.lastPrune) > time.Minute {
+		for h, v := range verified.keys {
+			if !now.Before(v.until) {
+				delete(verified.keys, h)
+			}
+		}
+		verified.lastPrune = now
+	}
+	verified.keys[hash] = verifiedKey{key: key, until: until}
+}
+
+// verifiedRateLimitKey - the key remembered for an access token, if it was
+// verified recently and has not expired since
+func verifiedRateLimitKey(tokenString string, now time.Time) (string, bool) {
+	verified.Lock()
+	defer verified.Unlock()
+	v, ok := verified.keys[HashAccessToken(tokenString)]
+	if !ok || !now.Before(v.until) {
+		return "", false
+	}
+	return v.key, true
+}
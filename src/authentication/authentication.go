@@ -12,33 +12,88 @@ import (
 	jwt "github.com/dgrijalva/jwt-go"
 )
 
-// Token - Generete a token with user's permissions
-func Token(userID uint64) (string, error) {
+// TokenLifetime - how long a login token, and so its session, lasts
+const TokenLifetime = 6 * time.Hour
+
+// Token - Generete a token with user's permissions for a session
+func Token(userID uint64, sessionID string) (string, error) {
 	permissions := jwt.MapClaims{}
 	permissions["authorized"] = true
-	permissions["exp"] = time.Now().Add(time.Hour * 6).Unix()
+	permissions["exp"] = time.Now().Add(TokenLifetime).Unix()
 	permissions["userID"] = userID
+	permissions["sid"] = sessionID
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, permissions)
-	return token.SignedString([]byte(config.SecretKey))
+	if !asymmetric() {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, permissions)
+		return token.SignedString([]byte(config.SecretKey))
+	}
+	key, err := currentKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.method, permissions)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.private)
 }
 
-// VerifyToken - make the token's validation
+// VerifyToken - make the token's validation, tokens of revoked sessions
+// or deleted accounts are refused
 func VerifyToken(r *http.Request) error {
 	tokenString := getToken(r)
 	token, err := jwt.Parse(tokenString, getSecret)
 	if err != nil {
 		return err
 	}
-	if _, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return nil
+	if permissions, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		// every login has had a session since sessions were added, the
+		// session check is also what refuses tokens of deleted accounts
+		if sessionID, ok := permissions["sid"].(string); ok {
+			return verifySession(r, sessionID)
+		}
+		return errors.New("Token has no session")
 	}
 	return errors.New("Token invalid")
 }
 
+// getSessionID - the sid claim of the request's token, if any
+func getSessionID(r *http.Request) string {
+	token, err := jwt.Parse(getToken(r), getSecret)
+	if err != nil {
+		return ""
+	}
+	if permissions, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		sessionID, _ := permissions["sid"].(string)
+		return sessionID
+	}
+	return ""
+}
+
 // GetUserID - Get userID from token
 func GetUserID(r *http.Request) (uint64, error) {
+	if id, ok := r.Context().Value(identityKey{}).(identity); ok {
+		if id.userID == 0 {
+			return 0, errors.New("Token does not belong to an user")
+		}
+		return id.userID, nil
+	}
 	tokenString := getToken(r)
+	if isAccessToken(tokenString) {
+		token, err := verifyAccessToken(r.Context(), tokenString)
+		if err != nil {
+			return 0, err
+		}
+		return token.UserID, nil
+	}
+	if isOAuthToken(tokenString) {
+		token, err := verifyOAuthToken(r.Context(), tokenString)
+		if err != nil {
+			return 0, err
+		}
+		if token.UserID == 0 {
+			return 0, errors.New("Token does not belong to an user")
+		}
+		return token.UserID, nil
+	}
 	token, err := jwt.Parse(tokenString, getSecret)
 	if err != nil {
 		return 0, err
@@ -55,18 +110,67 @@ func GetUserID(r *http.Request) (uint64, error) {
 	return 0, errors.New("Token invalid")
 }
 
+// RateLimitKey - who is calling, without a database round-trip: the user
+// of a validly signed JWT, or the owner of an access token Authentication
+// verified recently. Empty for anonymous callers and unverified tokens,
+// which share their IP's bucket.
+func RateLimitKey(r *http.Request) string {
+	tokenString := getToken(r)
+	if tokenString == "" {
+		return ""
+	}
+	if isAccessToken(tokenString) || isOAuthToken(tokenString) {
+		key, _ := verifiedRateLimitKey(tokenString, time.Now())
+		return key
+	}
+	token, err := jwt.Parse(tokenString, getSecret)
+	if err != nil {
+		return ""
+	}
+	if permissions, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		if userID, ok := permissions["userID"].(float64); ok {
+			return "user:" + strconv.FormatUint(uint64(userID), 10)
+		}
+	}
+	return ""
+}
+
+// IsAdmin - whether userID is listed in API_ADMINS
+func IsAdmin(userID uint64) bool {
+	return config.AdminIDs[userID]
+}
+
 func getToken(r *http.Request) string {
 	token := r.Header.Get("Authorization")
 
 	if len(strings.Split(token, " ")) == 2 {
 		return strings.Split(token, " ")[1]
 	}
+	if token == "" && config.AuthCookie {
+		if cookie, err := r.Cookie(config.AuthCookieName); err == nil {
+			return cookie.Value
+		}
+	}
 	return ""
 }
 
+// getSecret - the key that verifies token: the keyring entry named by its
+// kid, or JWT_SECRET for HS256 tokens. Under RS256 or EdDSA, HS256 tokens
+// are only accepted with JWT_ACCEPT_HS256, while migrating away from it.
 func getSecret(token *jwt.Token) (interface{}, error) {
-	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if len(config.SecretKey) == 0 || (asymmetric() && !config.JWTAcceptHS256) {
+			return nil, fmt.Errorf("Sign method error")
+		}
+		return config.SecretKey, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := findKey(kid)
+	if !ok {
+		return nil, fmt.Errorf("Unknown signing key")
+	}
+	if key.method.Alg() != token.Method.Alg() {
 		return nil, fmt.Errorf("Sign method error")
 	}
-	return config.SecretKey, nil
+	return key.private.Public(), nil
 }
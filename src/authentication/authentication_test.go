@@ -0,0 +1,45 @@
+package authentication
+
+import (
+	"api/src/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitKey(t *testing.T) {
+	defer func(secret []byte) { config.SecretKey = secret }(config.SecretKey)
+	config.SecretKey = []byte("test secret")
+	jwt, err := Token(7, "session-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	rememberVerified(HashAccessToken("pat_live"), "user:7", now.Add(time.Minute))
+	rememberVerified(HashAccessToken("oat_client"), "client:app", now.Add(time.Minute))
+	rememberVerified(HashAccessToken("pat_lapsed"), "user:7", now.Add(-time.Second))
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"anonymous", "", ""},
+		{"jwt", jwt, "user:7"},
+		{"forged jwt", jwt[:len(jwt)-4] + "AAAA", ""},
+		{"verified access token", "pat_live", "user:7"},
+		{"verified client token", "oat_client", "client:app"},
+		{"unknown access token", "pat_random", ""},
+		{"lapsed verification", "pat_lapsed", ""},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.token != "" {
+			request.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		if got := RateLimitKey(request); got != test.want {
+			t.Errorf("%s: RateLimitKey = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
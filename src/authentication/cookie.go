@@ -0,0 +1,77 @@
+package authentication
+
+import (
+	"api/src/config"
+	"crypto/subtle"
+	"net/http"
+	"time"
+)
+
+// CSRFHeader - header that must repeat the CSRF cookie on state-changing
+// requests authenticated by cookie
+const CSRFHeader = "X-CSRF-Token"
+
+// SetCookies - keep the login token in an HttpOnly cookie, next to a
+// CSRF token the browser app can read and send back in CSRFHeader
+func SetCookies(w http.ResponseWriter, token string) error {
+	csrf, _, err := NewSecret("")
+	if err != nil {
+		return err
+	}
+	expires := time.Now().Add(TokenLifetime)
+	http.SetCookie(w, &http.Cookie{
+		Name:     config.AuthCookieName,
+		Value:    token,
+		Path:     "/",
+		Domain:   config.CookieDomain,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   config.CookieSecure,
+		SameSite: config.CookieSameSite,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     config.CSRFCookieName,
+		Value:    csrf,
+		Path:     "/",
+		Domain:   config.CookieDomain,
+		Expires:  expires,
+		Secure:   config.CookieSecure,
+		SameSite: config.CookieSameSite,
+	})
+	return nil
+}
+
+// ClearCookies - remove the login and CSRF cookies
+func ClearCookies(w http.ResponseWriter) {
+	for _, name := range []string{config.AuthCookieName, config.CSRFCookieName} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			Domain:   config.CookieDomain,
+			MaxAge:   -1,
+			Secure:   config.CookieSecure,
+			SameSite: config.CookieSameSite,
+		})
+	}
+}
+
+// FromCookie - whether the request authenticates with the login cookie
+// instead of an Authorization header
+func FromCookie(r *http.Request) bool {
+	if !config.AuthCookie || r.Header.Get("Authorization") != "" {
+		return false
+	}
+	_, err := r.Cookie(config.AuthCookieName)
+	return err == nil
+}
+
+// VerifyCSRF - double-submit check: CSRFHeader must equal the CSRF cookie
+func VerifyCSRF(r *http.Request) bool {
+	cookie, err := r.Cookie(config.CSRFCookieName)
+	header := r.Header.Get(CSRFHeader)
+	if err != nil || cookie.Value == "" || header == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(header)) == 1
+}
@@ -0,0 +1,78 @@
+package authentication
+
+import (
+	"context"
+	"net/http"
+)
+
+// identity - who made the request, resolved once by Authenticate.
+// scopes is nil for logins, which may do anything the user can.
+type identity struct {
+	userID    uint64
+	scopes    []string
+	sessionID string
+}
+
+type identityKey struct{}
+
+// Authenticate - validate the request's JWT or personal access token and
+// return the request carrying the caller's identity
+func Authenticate(r *http.Request) (*http.Request, error) {
+	tokenString := getToken(r)
+	if isAccessToken(tokenString) {
+		token, err := verifyAccessToken(r.Context(), tokenString)
+		if err != nil {
+			return r, err
+		}
+		return withIdentity(r, identity{userID: token.UserID, scopes: token.Scopes}), nil
+	}
+	if isOAuthToken(tokenString) {
+		token, err := verifyOAuthToken(r.Context(), tokenString)
+		if err != nil {
+			return r, err
+		}
+		return withIdentity(r, identity{userID: token.UserID, scopes: token.Scopes}), nil
+	}
+
+	if err := VerifyToken(r); err != nil {
+		return r, err
+	}
+	userID, err := GetUserID(r)
+	if err != nil {
+		return r, err
+	}
+	return withIdentity(r, identity{userID: userID, sessionID: getSessionID(r)}), nil
+}
+
+// SessionID - session of the request's login, empty for access tokens
+func SessionID(r *http.Request) string {
+	if id, ok := r.Context().Value(identityKey{}).(identity); ok {
+		return id.sessionID
+	}
+	return getSessionID(r)
+}
+
+// HasScope - whether the caller may use scope. Access tokens never pass an
+// empty scope, routes must name the scope they need.
+func HasScope(r *http.Request, scope string) bool {
+	id, ok := r.Context().Value(identityKey{}).(identity)
+	if !ok {
+		return false
+	}
+	if id.scopes == nil {
+		return true
+	}
+	if scope == "" {
+		return false
+	}
+	for _, granted := range id.scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func withIdentity(r *http.Request, id identity) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), identityKey{}, id))
+}
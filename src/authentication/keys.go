@@ -0,0 +1,253 @@
+package authentication
+
+import (
+	"api/src/config"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// signingKey - an asymmetric key identified by kid
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	created time.Time
+}
+
+// keyring - the newest key signs, every key still inside its grace
+// period verifies
+var keyring struct {
+	sync.RWMutex
+	keys []signingKey
+}
+
+// JWK - public key in JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// asymmetric - whether tokens are signed with keys from the keyring
+func asymmetric() bool {
+	return config.JWTAlgorithm == "RS256" || config.JWTAlgorithm == "EdDSA"
+}
+
+// createdHeader - PEM header holding when a key was created, file times
+// don't survive copies and restores
+const createdHeader = "Created"
+
+// LoadKeys - load the keys from JWT_KEY_DIR and create one when none is
+// current. Nothing to do for HS256.
+func LoadKeys() error {
+	if !asymmetric() {
+		if config.JWTAlgorithm != "HS256" {
+			return fmt.Errorf("Unsupported JWT_ALGORITHM %q", config.JWTAlgorithm)
+		}
+		return nil
+	}
+	// keys only in memory would be lost on restart and differ per instance,
+	// invalidating every token issued by the others
+	if config.JWTKeyDir == "" {
+		return fmt.Errorf("JWT_KEY_DIR is required with JWT_ALGORITHM %s", config.JWTAlgorithm)
+	}
+	// a retired key must verify the last tokens it signed until they expire
+	if config.JWTKeyGrace < TokenLifetime {
+		return fmt.Errorf("JWT_KEY_GRACE must be at least the token lifetime (%s)", TokenLifetime)
+	}
+	return rotate(time.Now())
+}
+
+// RotateKeys - check for due rotations every interval, picking up keys
+// written by other instances sharing JWT_KEY_DIR
+func RotateKeys(interval time.Duration) {
+	if !asymmetric() {
+		return
+	}
+	for range time.Tick(interval) {
+		if err := rotate(time.Now()); err != nil {
+			log.Printf("rotate jwt keys: %v", err)
+		}
+	}
+}
+
+// JWKS - public keys that may still verify a token
+func JWKS() []JWK {
+	keyring.RLock()
+	defer keyring.RUnlock()
+
+	jwks := []JWK{}
+	for _, key := range keyring.keys {
+		jwk := JWK{KeyID: key.id, Use: "sig", Algorithm: key.method.Alg()}
+		switch public := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks
+}
+
+func currentKey() (signingKey, error) {
+	keyring.RLock()
+	defer keyring.RUnlock()
+	if len(keyring.keys) == 0 {
+		return signingKey{}, errors.New("No signing key loaded")
+	}
+	return keyring.keys[0], nil
+}
+
+func findKey(kid string) (signingKey, bool) {
+	keyring.RLock()
+	defer keyring.RUnlock()
+	for _, key := range keyring.keys {
+		if key.id == kid {
+			return key, true
+		}
+	}
+	return signingKey{}, false
+}
+
+// rotate - reload, add a key when the newest is older than JWT_ROTATION
+// and drop keys past JWT_ROTATION + JWT_KEY_GRACE
+func rotate(now time.Time) error {
+	keys, err := readKeys()
+	if err != nil {
+		return err
+	}
+
+	expired := now.Add(-(config.JWTRotation + config.JWTKeyGrace))
+	var kept []signingKey
+	for _, key := range keys {
+		if key.created.After(expired) {
+			kept = append(kept, key)
+			continue
+		}
+		os.Remove(filepath.Join(config.JWTKeyDir, key.id+".pem"))
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].created.After(kept[j].created) })
+
+	if len(kept) == 0 || kept[0].created.Before(now.Add(-config.JWTRotation)) {
+		key, err := newKey(now)
+		if err != nil {
+			return err
+		}
+		log.Printf("jwt: new %s signing key %s", key.method.Alg(), key.id)
+		kept = append([]signingKey{key}, kept...)
+	}
+
+	keyring.Lock()
+	keyring.keys = kept
+	keyring.Unlock()
+	return nil
+}
+
+func newKey(now time.Time) (signingKey, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return signingKey{}, err
+	}
+	key := signingKey{id: hex.EncodeToString(id), created: now}
+
+	var err error
+	switch config.JWTAlgorithm {
+	case "RS256":
+		key.method = jwt.SigningMethodRS256
+		key.private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "EdDSA":
+		key.method = signingMethodEd25519
+		_, key.private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		err = fmt.Errorf("Unsupported JWT_ALGORITHM %q", config.JWTAlgorithm)
+	}
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key.private)
+	if err != nil {
+		return signingKey{}, err
+	}
+	path := filepath.Join(config.JWTKeyDir, key.id+".pem")
+	data := pem.EncodeToMemory(&pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: map[string]string{createdHeader: now.UTC().Format(time.RFC3339)},
+		Bytes:   der,
+	})
+	if err = ioutil.WriteFile(path, data, 0600); err != nil {
+		return signingKey{}, err
+	}
+	return key, nil
+}
+
+// readKeys - <kid>.pem files from JWT_KEY_DIR, created at the time in
+// their Created header
+func readKeys() ([]signingKey, error) {
+	files, err := ioutil.ReadDir(config.JWTKeyDir)
+	if err != nil {
+		return nil, err
+	}
+	var keys []signingKey
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".pem") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(config.JWTKeyDir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%s: no PEM data", file.Name())
+		}
+		private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file.Name(), err)
+		}
+		created, err := time.Parse(time.RFC3339, block.Headers[createdHeader])
+		if err != nil {
+			return nil, fmt.Errorf("%s: no valid %s header", file.Name(), createdHeader)
+		}
+		key := signingKey{id: strings.TrimSuffix(file.Name(), ".pem"), created: created}
+		switch private := private.(type) {
+		case *rsa.PrivateKey:
+			key.method, key.private = jwt.SigningMethodRS256, private
+		case ed25519.PrivateKey:
+			key.method, key.private = signingMethodEd25519, private
+		default:
+			return nil, fmt.Errorf("%s: unsupported key type", file.Name())
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
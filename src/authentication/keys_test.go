@@ -0,0 +1,223 @@
+package authentication
+
+import (
+	"api/src/config"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// testKeys - sign with algorithm, keys in a fresh directory
+func testKeys(t *testing.T, algorithm string) {
+	t.Helper()
+	algorithmWas, dir, rotation, grace := config.JWTAlgorithm, config.JWTKeyDir, config.JWTRotation, config.JWTKeyGrace
+	secret, acceptHS256 := config.SecretKey, config.JWTAcceptHS256
+	t.Cleanup(func() {
+		config.JWTAlgorithm, config.JWTKeyDir, config.JWTRotation, config.JWTKeyGrace = algorithmWas, dir, rotation, grace
+		config.SecretKey, config.JWTAcceptHS256 = secret, acceptHS256
+		keyring.keys = nil
+	})
+	config.JWTAlgorithm, config.JWTKeyDir = algorithm, t.TempDir()
+	config.JWTRotation, config.JWTKeyGrace = 30*24*time.Hour, 12*time.Hour
+	config.SecretKey, config.JWTAcceptHS256 = []byte("test secret"), false
+	keyring.keys = nil
+}
+
+func verify(tokenString string) error {
+	_, err := jwt.Parse(tokenString, getSecret)
+	return err
+}
+
+func TestSignAndVerify(t *testing.T) {
+	for _, algorithm := range []string{"HS256", "RS256", "EdDSA"} {
+		t.Run(algorithm, func(t *testing.T) {
+			testKeys(t, algorithm)
+			if err := LoadKeys(); err != nil {
+				t.Fatal(err)
+			}
+			token, err := Token(1, "session")
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, _ := jwt.Parse(token, getSecret)
+			if parsed == nil || parsed.Method.Alg() != algorithm {
+				t.Fatalf("token signed with %v, want %s", parsed, algorithm)
+			}
+			if err = verify(token); err != nil {
+				t.Errorf("verify: %v", err)
+			}
+			if err = verify(token[:len(token)-4] + "AAAA"); err == nil {
+				t.Error("verified a tampered signature")
+			}
+		})
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		dir       bool
+		grace     time.Duration
+		wantErr   bool
+	}{
+		{"hs256 needs nothing", "HS256", false, 0, false},
+		{"rs256", "RS256", true, 12 * time.Hour, false},
+		{"eddsa", "EdDSA", true, TokenLifetime, false},
+		{"no key dir", "EdDSA", false, 12 * time.Hour, true},
+		{"grace shorter than tokens", "RS256", true, TokenLifetime - time.Minute, true},
+		{"unknown algorithm", "ES256", true, 12 * time.Hour, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testKeys(t, test.algorithm)
+			if !test.dir {
+				config.JWTKeyDir = ""
+			}
+			config.JWTKeyGrace = test.grace
+			if err := LoadKeys(); (err != nil) != test.wantErr {
+				t.Errorf("LoadKeys: %v, want error %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeysSurviveReload(t *testing.T) {
+	testKeys(t, "EdDSA")
+	now := time.Now()
+	if err := rotate(now); err != nil {
+		t.Fatal(err)
+	}
+	token, err := Token(1, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := currentKey()
+
+	// another instance, or a restart, reads the same directory
+	keyring.keys = nil
+	if err = rotate(now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := currentKey(); current.id != first.id || !current.created.Equal(first.created.Truncate(time.Second)) {
+		t.Errorf("reloaded key %s created %v, want %s created %v", current.id, current.created, first.id, first.created)
+	}
+	if err = verify(token); err != nil {
+		t.Errorf("verify after reload: %v", err)
+	}
+}
+
+func TestKeyWithoutCreatedHeader(t *testing.T) {
+	testKeys(t, "EdDSA")
+	if err := rotate(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	key, _ := currentKey()
+	der, err := x509.MarshalPKCS8PrivateKey(key.private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err = ioutil.WriteFile(filepath.Join(config.JWTKeyDir, "undated.pem"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = rotate(time.Now()); err == nil {
+		t.Error("loaded a key without its creation time")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	tests := []struct {
+		name       string
+		after      time.Duration
+		wantNewKey bool
+		wantValid  bool
+	}{
+		{"before rotation", 29 * 24 * time.Hour, false, true},
+		{"rotated, in grace", 30*24*time.Hour + time.Hour, true, true},
+		{"past grace", 30*24*time.Hour + 13*time.Hour, true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testKeys(t, "RS256")
+			start := time.Now()
+			if err := rotate(start); err != nil {
+				t.Fatal(err)
+			}
+			old, _ := currentKey()
+			token, err := Token(1, "session")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = rotate(start.Add(test.after)); err != nil {
+				t.Fatal(err)
+			}
+			current, _ := currentKey()
+			if newKey := current.id != old.id; newKey != test.wantNewKey {
+				t.Errorf("new signing key %v, want %v", newKey, test.wantNewKey)
+			}
+			if err = verify(token); (err == nil) != test.wantValid {
+				t.Errorf("verify token of the old key: %v, want valid %v", err, test.wantValid)
+			}
+		})
+	}
+}
+
+func TestAlgorithmConfusion(t *testing.T) {
+	testKeys(t, "RS256")
+	if err := LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+	key, _ := currentKey()
+	publicDER, err := x509.MarshalPKIXPublicKey(key.private.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	claims := jwt.MapClaims{"userID": 1, "sid": "session", "exp": time.Now().Add(time.Hour).Unix()}
+
+	sign := func(method jwt.SigningMethod, kid string, secret interface{}) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	_, otherEd25519, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		token       string
+		acceptHS256 bool
+		wantValid   bool
+	}{
+		{"hs256 signed with the public key", sign(jwt.SigningMethodHS256, key.id, publicPEM), false, false},
+		{"hs256 signed with the public key, in transition", sign(jwt.SigningMethodHS256, key.id, publicPEM), true, false},
+		{"hs256 with the secret", sign(jwt.SigningMethodHS256, "", config.SecretKey), false, false},
+		{"hs256 with the secret, in transition", sign(jwt.SigningMethodHS256, "", config.SecretKey), true, true},
+		{"eddsa under an rsa kid", sign(signingMethodEd25519, key.id, otherEd25519), false, false},
+		{"unknown kid", sign(jwt.SigningMethodRS256, "missing", key.private), false, false},
+		{"none", sign(jwt.SigningMethodNone, key.id, jwt.UnsafeAllowNoneSignatureType), false, false},
+	}
+	for _, test := range tests {
+		config.JWTAcceptHS256 = test.acceptHS256
+		if err := verify(test.token); (err == nil) != test.wantValid {
+			t.Errorf("%s: verify = %v, want valid %v", test.name, err, test.wantValid)
+		}
+	}
+}
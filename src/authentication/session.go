@@ -0,0 +1,64 @@
+package authentication
+
+import (
+	"api/src/database"
+	"api/src/models"
+	"api/src/repository"
+	"api/src/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// NewSession - start a session for userID on the device making r
+func NewSession(r *http.Request, userID uint64) (models.Session, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return models.Session{}, err
+	}
+	userAgent := r.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	session := models.Session{
+		ID:        hex.EncodeToString(id),
+		UserID:    userID,
+		UserAgent: userAgent,
+		IP:        utils.ClientIP(r),
+	}
+
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		return models.Session{}, err
+	}
+	defer db.Close()
+
+	if err = repository.NewSessionRepo(db).Create(r.Context(), session); err != nil {
+		return models.Session{}, err
+	}
+	return session, nil
+}
+
+func verifySession(r *http.Request, sessionID string) error {
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	sessionRepo := repository.NewSessionRepo(db)
+	active, err := sessionRepo.Active(r.Context(), sessionID)
+	if err != nil {
+		return err
+	}
+	if !active {
+		return errors.New("Session revoked")
+	}
+	if err = sessionRepo.Touch(r.Context(), sessionID, utils.ClientIP(r), time.Now()); err != nil {
+		log.Printf("session %s last seen: %v", sessionID, err)
+	}
+	return nil
+}
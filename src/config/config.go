@@ -1,10 +1,17 @@
 package config
 
 import (
+	"crypto/rand"
 	"fmt"
 	"log"
+	"math"
+	"net"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -14,6 +21,98 @@ var (
 	Port      = 0
 	// Token key
 	SecretKey []byte
+	// QueryTimeout - default deadline for a request's database work
+	QueryTimeout = 5 * time.Second
+	// RouteTimeouts - per-route overrides keyed by "METHOD /uri"
+	RouteTimeouts = map[string]time.Duration{}
+	// AdminIDs - users allowed on admin routes
+	AdminIDs = map[uint64]bool{}
+	// TrustedProxies - networks whose X-Forwarded-For entries are believed,
+	// the client IP is the rightmost hop outside of them
+	TrustedProxies []*net.IPNet
+
+	// Login throttling
+	LoginThrottleStore = "memory"
+	LoginMaxAttempts   = 5
+	LoginMaxAttemptsIP = 20
+	LoginLockout       = 15 * time.Minute
+	LoginBackoffBase   = time.Second
+	LoginBackoffMax    = 30 * time.Second
+
+	// Rate limiting defaults for routes without their own RateLimit
+	RateLimitStore    = "memory"
+	RateLimitRequests = 120
+	RateLimitWindow   = time.Minute
+
+	// CORS, no origin is allowed unless configured
+	CorsAllowedOrigins   []string
+	CorsAllowedMethods   []string
+	CorsAllowedHeaders   = []string{"Authorization", "Content-Type", "X-CSRF-Token"}
+	CorsAllowCredentials = false
+	CorsMaxAge           = 10 * time.Minute
+
+	// Password hashing, "argon2id" or "bcrypt"
+	PasswordAlgorithm        = "argon2id"
+	BcryptCost               = 10
+	Argon2Memory      uint32 = 64 * 1024
+	Argon2Iterations  uint32 = 3
+	Argon2Parallelism uint8  = 2
+
+	// Password policy
+	PasswordMinLength        = 8
+	PasswordMaxBytes         = 72
+	PasswordCharacterClasses = 3
+	BreachedPasswordsFile    = ""
+
+	// Token signing, "HS256" (JWT_SECRET), "RS256" or "EdDSA"
+	JWTAlgorithm = "HS256"
+	JWTKeyDir    = ""
+	JWTRotation  = 30 * 24 * time.Hour
+	JWTKeyGrace  = 12 * time.Hour
+	// JWTAcceptHS256 - keep verifying JWT_SECRET tokens under RS256 or
+	// EdDSA, only while moving away from HS256
+	JWTAcceptHS256 = false
+
+	// External OpenID Connect provider, disabled without an issuer
+	OIDCIssuer       = ""
+	OIDCClientID     = ""
+	OIDCClientSecret = ""
+	OIDCRedirectURL  = ""
+
+	// OAuthTokenLifetime - lifetime of tokens issued to OAuth2 clients
+	OAuthTokenLifetime = time.Hour
+	// OAuthRedirectSchemes - custom schemes native apps may register as
+	// redirect uris, besides https and http on loopback
+	OAuthRedirectSchemes = []string{}
+
+	// Cookie login for browser clients, off unless AUTH_COOKIE=true
+	AuthCookie     = false
+	AuthCookieName = "token"
+	CSRFCookieName = "csrf_token"
+	CookieDomain   = ""
+	CookieSecure   = true
+	CookieSameSite = http.SameSiteStrictMode
+
+	// AuditRetention - how long audit entries are kept, zero keeps them all
+	AuditRetention = 365 * 24 * time.Hour
+	// AccountRestoreWindow - how long a deleted account can log in to be
+	// restored before it is purged
+	AccountRestoreWindow = 30 * 24 * time.Hour
+
+	// Personal data exports
+	ExportDir        = filepath.Join(os.TempDir(), "api-exports")
+	ExportLinkTTL    = 24 * time.Hour
+	ExportSigningKey []byte
+	// PublicURL - base of links sent to users, e.g. https://api.example.com
+	PublicURL = ""
+
+	// Direct messages
+	MessageMaxMembers = 10
+	MessageMaxLength  = 2000
+
+	// Follow suggestions are cached per user for SuggestionsTTL
+	SuggestionsTTL       = 6 * time.Hour
+	SuggestionsCacheSize = 50
 )
 
 // Config - Load all configs
@@ -35,4 +134,209 @@ func Config() {
 	)
 	SecretKey = []byte(os.Getenv("JWT_SECRET"))
 
+	QueryTimeout = duration("DB_QUERY_TIMEOUT", QueryTimeout)
+	RouteTimeouts = routeTimeouts(os.Getenv("ROUTE_TIMEOUTS"))
+	AdminIDs = ids(os.Getenv("API_ADMINS"))
+	TrustedProxies = networks("TRUSTED_PROXIES", nil)
+	if len(TrustedProxies) == 0 && os.Getenv("TRUST_PROXY") == "true" {
+		// a proxy on the same host or private network
+		TrustedProxies = networks("", []string{"127.0.0.0/8", "::1/128", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"})
+	}
+
+	if store := os.Getenv("LOGIN_THROTTLE_STORE"); store != "" {
+		LoginThrottleStore = store
+	}
+	LoginMaxAttempts = integer("LOGIN_MAX_ATTEMPTS", LoginMaxAttempts)
+	LoginMaxAttemptsIP = integer("LOGIN_MAX_ATTEMPTS_IP", LoginMaxAttemptsIP)
+	LoginLockout = duration("LOGIN_LOCKOUT", LoginLockout)
+	LoginBackoffBase = duration("LOGIN_BACKOFF_BASE", LoginBackoffBase)
+	LoginBackoffMax = duration("LOGIN_BACKOFF_MAX", LoginBackoffMax)
+
+	if store := os.Getenv("RATE_LIMIT_STORE"); store != "" {
+		RateLimitStore = store
+	}
+	RateLimitRequests = integer("RATE_LIMIT_REQUESTS", RateLimitRequests)
+	RateLimitWindow = duration("RATE_LIMIT_WINDOW", RateLimitWindow)
+	if RateLimitRequests == 0 || RateLimitWindow <= 0 {
+		log.Fatal("RATE_LIMIT_REQUESTS must not be 0 and RATE_LIMIT_WINDOW must be positive")
+	}
+
+	CorsAllowedOrigins = list("CORS_ALLOWED_ORIGINS", CorsAllowedOrigins)
+	CorsAllowedMethods = list("CORS_ALLOWED_METHODS", CorsAllowedMethods)
+	CorsAllowedHeaders = list("CORS_ALLOWED_HEADERS", CorsAllowedHeaders)
+	CorsAllowCredentials = os.Getenv("CORS_ALLOW_CREDENTIALS") == "true"
+	CorsMaxAge = duration("CORS_MAX_AGE", CorsMaxAge)
+	for _, origin := range CorsAllowedOrigins {
+		if origin == "*" && CorsAllowCredentials {
+			// browsers would let any site make credentialed reads
+			log.Fatal("CORS_ALLOWED_ORIGINS=* can't be combined with CORS_ALLOW_CREDENTIALS=true")
+		}
+	}
+
+	if algorithm := os.Getenv("PASSWORD_ALGORITHM"); algorithm != "" {
+		PasswordAlgorithm = algorithm
+	}
+	if PasswordAlgorithm != "argon2id" && PasswordAlgorithm != "bcrypt" {
+		log.Fatalf("PASSWORD_ALGORITHM must be argon2id or bcrypt, not %q", PasswordAlgorithm)
+	}
+	BcryptCost = integer("BCRYPT_COST", BcryptCost)
+	if BcryptCost < 4 || BcryptCost > 31 {
+		log.Fatalf("BCRYPT_COST must be between 4 and 31, not %d", BcryptCost)
+	}
+	// argon2.IDKey panics on zero threads or iterations
+	memory := integer("ARGON2_MEMORY", int(Argon2Memory))
+	iterations := integer("ARGON2_ITERATIONS", int(Argon2Iterations))
+	parallelism := integer("ARGON2_PARALLELISM", int(Argon2Parallelism))
+	if parallelism < 1 || parallelism > 255 {
+		log.Fatalf("ARGON2_PARALLELISM must be between 1 and 255, not %d", parallelism)
+	}
+	if iterations < 1 || iterations > math.MaxUint32 {
+		log.Fatalf("ARGON2_ITERATIONS must be at least 1, not %d", iterations)
+	}
+	if memory < 8*parallelism || memory > math.MaxUint32 {
+		log.Fatalf("ARGON2_MEMORY must be at least 8 KiB per thread, not %d", memory)
+	}
+	Argon2Memory, Argon2Iterations, Argon2Parallelism = uint32(memory), uint32(iterations), uint8(parallelism)
+
+	PasswordMinLength = integer("PASSWORD_MIN_LENGTH", PasswordMinLength)
+	PasswordMaxBytes = integer("PASSWORD_MAX_BYTES", PasswordMaxBytes)
+	PasswordCharacterClasses = integer("PASSWORD_CHARACTER_CLASSES", PasswordCharacterClasses)
+	BreachedPasswordsFile = os.Getenv("BREACHED_PASSWORDS_FILE")
+
+	if algorithm := os.Getenv("JWT_ALGORITHM"); algorithm != "" {
+		JWTAlgorithm = algorithm
+	}
+	JWTKeyDir = os.Getenv("JWT_KEY_DIR")
+	JWTRotation = duration("JWT_ROTATION", JWTRotation)
+	JWTKeyGrace = duration("JWT_KEY_GRACE", JWTKeyGrace)
+	JWTAcceptHS256 = os.Getenv("JWT_ACCEPT_HS256") == "true"
+
+	OIDCIssuer = os.Getenv("OIDC_ISSUER")
+	OIDCClientID = os.Getenv("OIDC_CLIENT_ID")
+	OIDCClientSecret = os.Getenv("OIDC_CLIENT_SECRET")
+	OIDCRedirectURL = os.Getenv("OIDC_REDIRECT_URL")
+
+	OAuthTokenLifetime = duration("OAUTH_TOKEN_LIFETIME", OAuthTokenLifetime)
+	OAuthRedirectSchemes = list("OAUTH_REDIRECT_SCHEMES", OAuthRedirectSchemes)
+
+	AuthCookie = os.Getenv("AUTH_COOKIE") == "true"
+	CookieDomain = os.Getenv("COOKIE_DOMAIN")
+	// only for local development over plain http
+	CookieSecure = os.Getenv("COOKIE_SECURE") != "false"
+	switch os.Getenv("COOKIE_SAMESITE") {
+	case "lax":
+		CookieSameSite = http.SameSiteLaxMode
+	case "none":
+		CookieSameSite = http.SameSiteNoneMode
+	}
+
+	AuditRetention = duration("AUDIT_RETENTION", AuditRetention)
+	AccountRestoreWindow = duration("ACCOUNT_RESTORE_WINDOW", AccountRestoreWindow)
+
+	if dir := os.Getenv("EXPORT_DIR"); dir != "" {
+		ExportDir = dir
+	}
+	ExportLinkTTL = duration("EXPORT_LINK_TTL", ExportLinkTTL)
+	// never JWT_SECRET, a leaked link key mustn't sign logins
+	ExportSigningKey = []byte(os.Getenv("EXPORT_SIGNING_KEY"))
+	if len(ExportSigningKey) == 0 {
+		// links won't survive a restart nor work across instances
+		log.Print("EXPORT_SIGNING_KEY not set, using a random key")
+		ExportSigningKey = make([]byte, 32)
+		if _, err := rand.Read(ExportSigningKey); err != nil {
+			log.Fatal(err)
+		}
+	}
+	PublicURL = strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+
+	MessageMaxMembers = integer("MESSAGE_MAX_MEMBERS", MessageMaxMembers)
+	MessageMaxLength = integer("MESSAGE_MAX_LENGTH", MessageMaxLength)
+
+	SuggestionsTTL = duration("SUGGESTIONS_TTL", SuggestionsTTL)
+	SuggestionsCacheSize = integer("SUGGESTIONS_CACHE_SIZE", SuggestionsCacheSize)
+}
+
+// integer - read an int from env, or fallback
+func integer(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// duration - read a time.Duration ("500ms", "10s") from env, or fallback
+func duration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// routeTimeouts - parse "GET /users=2s;POST /login=1s" into a map
+func routeTimeouts(value string) map[string]time.Duration {
+	timeouts := map[string]time.Duration{}
+	for _, entry := range strings.Split(value, ";") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil {
+			log.Printf("ROUTE_TIMEOUTS: ignoring %q: %v", entry, err)
+			continue
+		}
+		timeouts[strings.TrimSpace(parts[0])] = timeout
+	}
+	return timeouts
+}
+
+// list - read a comma separated list from env, or fallback
+func list(key string, fallback []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// networks - read a comma separated list of CIDRs or single IPs from env,
+// or fallback
+func networks(key string, fallback []string) []*net.IPNet {
+	var parsed []*net.IPNet
+	for _, entry := range list(key, fallback) {
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Fatalf("%s: %v", key, err)
+		}
+		parsed = append(parsed, network)
+	}
+	return parsed
+}
+
+// ids - parse a comma separated list of user ids
+func ids(value string) map[uint64]bool {
+	ids := map[uint64]bool{}
+	for _, field := range strings.Split(value, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(field), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids[id] = true
+	}
+	return ids
 }
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"api/src/audit"
+	"api/src/authentication"
+	"api/src/database"
+	"api/src/models"
+	"api/src/repository"
+	"api/src/utils"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateAccessToken - create a personal access token for the token's user.
+// The token is only ever returned in this response.
+func CreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		utils.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	var token models.AccessToken
+	if err = json.Unmarshal(body, &token); err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	if err = token.Prepare(); err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	token.UserID = userID
+	token.Token, token.Hash, err = authentication.NewAccessToken()
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	tokenRepo := repository.NewAccessTokenRepo(db)
+	token.ID, err = tokenRepo.Create(r.Context(), token)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	audit.Record(r, db, models.AuditEntry{
+		Action:     audit.TokenCreated,
+		TargetType: "access_token",
+		TargetID:   strconv.FormatUint(token.ID, 10),
+	})
+	utils.JSON(w, http.StatusCreated, token)
+}
+
+// GetAccessTokens - list the token's user access tokens
+func GetAccessTokens(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	tokenRepo := repository.NewAccessTokenRepo(db)
+	tokens, err := tokenRepo.FindByUser(r.Context(), userID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, tokens)
+}
+
+// DeleteAccessToken - revoke one of the token's user access tokens
+func DeleteAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	params := mux.Vars(r)
+	tokenID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	tokenRepo := repository.NewAccessTokenRepo(db)
+	deleted, err := tokenRepo.Delete(r.Context(), userID, tokenID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !deleted {
+		utils.Error(w, http.StatusNotFound, errors.New("Token not found"))
+		return
+	}
+	audit.Record(r, db, models.AuditEntry{
+		Action:     audit.TokenRevoked,
+		TargetType: "access_token",
+		TargetID:   params["id"],
+	})
+	utils.JSON(w, http.StatusNoContent, nil)
+}
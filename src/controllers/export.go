@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/database"
+	"api/src/export"
+	"api/src/jobs"
+	"api/src/models"
+	"api/src/repository"
+	"api/src/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateExport - start building an archive of everything held about the
+// user, the user is notified when it is ready
+func CreateExport(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	// Verify userID params with userID from token
+	userIDToken, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	if userIDToken != userID {
+		utils.Error(w, http.StatusForbidden, errors.New("User unauthorized"))
+		return
+	}
+
+	id := make([]byte, 16)
+	if _, err = rand.Read(id); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	dataExport := models.DataExport{ID: hex.EncodeToString(id), UserID: userID, Status: models.ExportPending}
+
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	exportRepo := repository.NewDataExportRepo(db)
+	created, err := exportRepo.Create(r.Context(), dataExport, export.Owner, time.Now().Add(export.LeaseFor))
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !created {
+		// one archive at a time, answer with the export being built
+		pending, err := exportRepo.FindPending(r.Context(), userID)
+		if err != nil {
+			utils.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if len(pending) > 0 {
+			w.Header().Set("Location", "/users/"+params["id"]+"/exports/"+pending[0].ID)
+			utils.JSON(w, http.StatusAccepted, pending[0])
+			return
+		}
+		utils.Error(w, http.StatusConflict, errors.New("Export already in progress"))
+		return
+	}
+	if err = jobs.Enqueue("export "+dataExport.ID, export.Job(dataExport.ID, userID)); err != nil {
+		if deleteErr := exportRepo.Delete(r.Context(), dataExport.ID); deleteErr != nil {
+			log.Printf("export %s: %v", dataExport.ID, deleteErr)
+		}
+		utils.Error(w, http.StatusServiceUnavailable, errors.New("Too many exports in progress, try again later"))
+		return
+	}
+
+	w.Header().Set("Location", "/users/"+params["id"]+"/exports/"+dataExport.ID)
+	utils.JSON(w, http.StatusAccepted, dataExport)
+}
+
+// GetExport - status of an export, with its download link once ready
+func GetExport(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	// Verify userID params with userID from token
+	userIDToken, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	if userIDToken != userID {
+		utils.Error(w, http.StatusForbidden, errors.New("User unauthorized"))
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	dataExport, err := repository.NewDataExportRepo(db).Find(r.Context(), params["exportID"])
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if dataExport.ID == "" || dataExport.UserID != userID {
+		utils.Error(w, http.StatusNotFound, errors.New("Export not found"))
+		return
+	}
+	utils.JSON(w, http.StatusOK, export.WithLink(dataExport))
+}
+
+// DownloadExport - serve an archive to whoever holds a valid signed link
+func DownloadExport(w http.ResponseWriter, r *http.Request) {
+	exportID := mux.Vars(r)["id"]
+	query := r.URL.Query()
+	if !export.Verify(exportID, query.Get("expires"), query.Get("signature")) {
+		utils.Error(w, http.StatusForbidden, errors.New("Download link invalid or expired"))
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	dataExport, err := repository.NewDataExportRepo(db).Find(r.Context(), exportID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if dataExport.Status != models.ExportReady {
+		utils.Error(w, http.StatusNotFound, errors.New("Export not found"))
+		return
+	}
+	archive, err := export.Open(r.Context(), dataExport)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer archive.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="export-`+exportID+`.zip"`)
+	if _, err = io.Copy(w, archive); err != nil {
+		log.Printf("download export %s: %v", exportID, err)
+	}
+}
@@ -0,0 +1,17 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/utils"
+	"net/http"
+)
+
+// JWKS - publish the public keys that verify our tokens
+func JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	utils.JSON(w, http.StatusOK, struct {
+		Keys []authentication.JWK `json:"keys"`
+	}{
+		Keys: authentication.JWKS(),
+	})
+}
@@ -0,0 +1,355 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/database"
+	"api/src/models"
+	"api/src/repository"
+	"api/src/utils"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateList - create a list owned by the token's user
+func CreateList(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		utils.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	var list models.List
+	if err = json.Unmarshal(body, &list); err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	if err = list.Prepare(); err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	list.OwnerID = userID
+	if list.ID, err = repository.NewListRepo(db).Create(r.Context(), list); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusCreated, list)
+}
+
+// GetList - a public list, or a private one to its owner
+func GetList(w http.ResponseWriter, r *http.Request) {
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	list, _, status, err := visibleList(r, repository.NewListRepo(db))
+	if err != nil {
+		utils.Error(w, status, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, list)
+}
+
+// UpdateList - change a list, owner only
+func UpdateList(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		utils.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	var data models.List
+	if err = json.Unmarshal(body, &data); err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	if err = data.Prepare(); err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	listRepo := repository.NewListRepo(db)
+	list, status, err := ownedList(r, listRepo)
+	if err != nil {
+		utils.Error(w, status, err)
+		return
+	}
+	if err = listRepo.Update(r.Context(), list.ID, data); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if list, err = listRepo.FindById(r.Context(), list.ID); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, list)
+}
+
+// DeleteList - remove a list, owner only
+func DeleteList(w http.ResponseWriter, r *http.Request) {
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	listRepo := repository.NewListRepo(db)
+	list, status, err := ownedList(r, listRepo)
+	if err != nil {
+		utils.Error(w, status, err)
+		return
+	}
+	if err = listRepo.Delete(r.Context(), list.ID); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusNoContent, nil)
+}
+
+// GetUserLists - lists created by an user, private ones only to the user
+func GetUserLists(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	userIDToken, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	lists, err := repository.NewListRepo(db).FindByOwner(r.Context(), userID, userID == userIDToken)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, lists)
+}
+
+// GetListSubscriptions - lists the token's user subscribed to
+func GetListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	// Verify userID params with userID from token
+	userIDToken, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	if userIDToken != userID {
+		utils.Error(w, http.StatusForbidden, errors.New("User unauthorized"))
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	lists, err := repository.NewListRepo(db).FindSubscribed(r.Context(), userID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, lists)
+}
+
+// GetListMembers - accounts in a list
+func GetListMembers(w http.ResponseWriter, r *http.Request) {
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	listRepo := repository.NewListRepo(db)
+	list, _, status, err := visibleList(r, listRepo)
+	if err != nil {
+		utils.Error(w, status, err)
+		return
+	}
+	members, err := listRepo.Members(r.Context(), list.ID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, members)
+}
+
+// AddListMember - add an account to a list, owner only
+func AddListMember(w http.ResponseWriter, r *http.Request) {
+	memberID, err := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	listRepo := repository.NewListRepo(db)
+	list, status, err := ownedList(r, listRepo)
+	if err != nil {
+		utils.Error(w, status, err)
+		return
+	}
+	found, err := listRepo.AddMember(r.Context(), list.ID, memberID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !found {
+		utils.Error(w, http.StatusNotFound, errors.New("User not found"))
+		return
+	}
+	utils.JSON(w, http.StatusNoContent, nil)
+}
+
+// RemoveListMember - remove an account from a list, owner only
+func RemoveListMember(w http.ResponseWriter, r *http.Request) {
+	memberID, err := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	listRepo := repository.NewListRepo(db)
+	list, status, err := ownedList(r, listRepo)
+	if err != nil {
+		utils.Error(w, status, err)
+		return
+	}
+	if err = listRepo.RemoveMember(r.Context(), list.ID, memberID); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusNoContent, nil)
+}
+
+// SubscribeList - subscribe the token's user to a list they can see
+func SubscribeList(w http.ResponseWriter, r *http.Request) {
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	listRepo := repository.NewListRepo(db)
+	list, userID, status, err := visibleList(r, listRepo)
+	if err != nil {
+		utils.Error(w, status, err)
+		return
+	}
+	if err = listRepo.Subscribe(r.Context(), list.ID, userID); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusNoContent, nil)
+}
+
+// UnsubscribeList - remove the token's user subscription to a list
+func UnsubscribeList(w http.ResponseWriter, r *http.Request) {
+	listID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	if err = repository.NewListRepo(db).Unsubscribe(r.Context(), listID, userID); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusNoContent, nil)
+}
+
+// visibleList - the list in the URI when the token's user may see it, with
+// that user's id. Private lists of others answer 404 like missing ones.
+func visibleList(r *http.Request, listRepo *repository.ListRepo) (models.List, uint64, int, error) {
+	listID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return models.List{}, 0, http.StatusBadRequest, err
+	}
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		return models.List{}, 0, http.StatusUnauthorized, err
+	}
+	list, err := listRepo.FindById(r.Context(), listID)
+	if err != nil {
+		return models.List{}, 0, http.StatusInternalServerError, err
+	}
+	if !list.VisibleTo(userID) {
+		return models.List{}, 0, http.StatusNotFound, errors.New("List not found")
+	}
+	return list, userID, 0, nil
+}
+
+// ownedList - the list in the URI when the token's user owns it
+func ownedList(r *http.Request, listRepo *repository.ListRepo) (models.List, int, error) {
+	list, userID, status, err := visibleList(r, listRepo)
+	if err != nil {
+		return list, status, err
+	}
+	if list.OwnerID != userID {
+		return models.List{}, http.StatusForbidden, errors.New("User unauthorized")
+	}
+	return list, 0, nil
+}
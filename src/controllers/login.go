@@ -1,17 +1,35 @@
 package controllers
 
 import (
+	"api/src/audit"
 	"api/src/authentication"
+	"api/src/config"
 	"api/src/database"
 	"api/src/hash"
 	"api/src/models"
 	"api/src/repository"
+	"api/src/throttle"
 	"api/src/utils"
+	"context"
+	"crypto/rand"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
 )
 
+// loginAttempts - in-memory throttle store used unless LOGIN_THROTTLE_STORE=sql
+var loginAttempts = throttle.NewMemoryStore()
+
 // Login - Make the users's authentication
 func Login(w http.ResponseWriter, r *http.Request) {
 	body, err := ioutil.ReadAll(r.Body)
@@ -24,26 +42,245 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		utils.Error(w, http.StatusBadRequest, err)
 		return
 	}
-	db, error := database.Connect()
+	db, error := database.Connect(r.Context())
 	if error != nil {
 		utils.Error(w, http.StatusInternalServerError, error)
 		return
 	}
 	defer db.Close()
+
+	ip := utils.ClientIP(r)
+	throttler := loginThrottler(db)
+	wait, err := throttler.Check(r.Context(), user.Email, ip)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if wait > 0 {
+		tooManyAttempts(w, wait)
+		return
+	}
+
+	// counted before the password is checked, parallel guesses past the
+	// limit are refused here instead of all passing Check
+	reservation, err := throttler.Reserve(r.Context(), user.Email, ip)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !reservation.Allowed {
+		tooManyAttempts(w, reservation.Wait)
+		return
+	}
+
 	userRepo := repository.NewUserRepo(db)
-	userFound, err := userRepo.FindByEmail(user.Email)
-	if error != nil {
-		utils.Error(w, http.StatusInternalServerError, error)
+	userFound, err := userRepo.FindByEmail(r.Context(), user.Email)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
 		return
 	}
-	if err = hash.Verify(user.Password, userFound.Password); err != nil {
+	// no account, or one that only logs in through a provider: check a
+	// decoy so the answer takes as long and reads the same as a mismatch
+	passwordHash := userFound.Password
+	usable := passwordHash != "" && passwordHash != unusablePassword
+	if !usable {
+		passwordHash = decoyHash()
+	}
+	needsRehash, err := hash.Verify(user.Password, passwordHash)
+	if err == nil && !usable {
+		err = hash.ErrMismatch
+	}
+	if err != nil && err != hash.ErrMismatch {
+		log.Printf("login: user %d: %v", userFound.ID, err)
+		err = hash.ErrMismatch
+	}
+	if err != nil {
+		// the caller isn't known, the account owner is the target
+		audit.Record(r, db, models.AuditEntry{
+			Action:     audit.LoginFailed,
+			TargetType: "email",
+			TargetID:   user.Email,
+		})
+		for _, key := range reservation.Locked {
+			audit.Record(r, db, models.AuditEntry{
+				Action:     audit.LoginLocked,
+				TargetType: "throttle",
+				TargetID:   key,
+			})
+		}
+		setRetryAfter(w, reservation.Wait)
 		utils.Error(w, http.StatusUnauthorized, err)
 		return
 	}
-	token, err := authentication.Token(userFound.ID)
+	if err = throttler.Success(r.Context(), user.Email, ip); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if needsRehash {
+		rehash(r.Context(), userRepo, userFound.ID, user.Password)
+	}
+	if status, err := restoreOnLogin(r, db, userFound.ID, userFound.DeactivatedAt); err != nil {
+		utils.Error(w, status, err)
+		return
+	}
+	session, err := authentication.NewSession(r, userFound.ID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	token, err := authentication.Token(userFound.ID, session.ID)
 	if err != nil {
 		utils.Error(w, http.StatusInternalServerError, err)
 		return
 	}
+	audit.Record(r, db, models.AuditEntry{
+		ActorID:    userFound.ID,
+		Action:     audit.Login,
+		TargetType: "session",
+		TargetID:   session.ID,
+	})
+	// browser clients ask for the token as an HttpOnly cookie
+	writeToken(w, token, r.URL.Query().Get("cookie") == "true")
+}
+
+var (
+	decoy     string
+	decoyOnce sync.Once
+)
+
+// decoyHash - hash of a random password, made once with the current
+// algorithm and cost
+func decoyHash() string {
+	decoyOnce.Do(func() {
+		random := make([]byte, 32)
+		rand.Read(random)
+		hashed, err := hash.Hash(string(random))
+		if err != nil {
+			log.Printf("login: decoy hash: %v", err)
+			return
+		}
+		decoy = string(hashed)
+	})
+	return decoy
+}
+
+// writeToken - answer a login with the token, or set it as cookies when the
+// client asked for it and cookie login is enabled
+func writeToken(w http.ResponseWriter, token string, cookie bool) {
+	if config.AuthCookie && cookie {
+		if err := authentication.SetCookies(w, token); err != nil {
+			utils.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		utils.JSON(w, http.StatusNoContent, nil)
+		return
+	}
 	w.Write([]byte(token))
 }
+
+// Logout - end the current session and clear the login cookies
+func Logout(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	sessionID := authentication.SessionID(r)
+	if sessionID != "" {
+		if _, err = repository.NewSessionRepo(db).Revoke(r.Context(), userID, sessionID); err != nil {
+			utils.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	audit.Record(r, db, models.AuditEntry{
+		Action:     audit.Logout,
+		TargetType: "session",
+		TargetID:   sessionID,
+	})
+	authentication.ClearCookies(w)
+	utils.JSON(w, http.StatusNoContent, nil)
+}
+
+// UnlockLogin - clear an account's failed logins and lockout
+func UnlockLogin(w http.ResponseWriter, r *http.Request) {
+	email := mux.Vars(r)["email"]
+
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	if err = loginThrottler(db).Unlock(r.Context(), email); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	audit.Record(r, db, models.AuditEntry{
+		Action:     audit.LoginUnlocked,
+		TargetType: "email",
+		TargetID:   email,
+	})
+	utils.JSON(w, http.StatusNoContent, nil)
+}
+
+// rehash - store the password with the current algorithm and parameters.
+// Failing here must not fail the login, the old hash still works.
+func rehash(ctx context.Context, userRepo *repository.UserRepo, userID uint64, password string) {
+	passwordHash, err := hash.Hash(password)
+	if err == nil {
+		err = userRepo.UpdatePassword(ctx, userID, string(passwordHash))
+	}
+	if err != nil {
+		log.Printf("rehash password for user %d: %v", userID, err)
+	}
+}
+
+func loginThrottler(db *sql.DB) *throttle.Throttler {
+	if config.LoginThrottleStore == "sql" {
+		return throttle.New(repository.NewLoginAttemptRepo(db))
+	}
+	return throttle.New(loginAttempts)
+}
+
+func tooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	setRetryAfter(w, wait)
+	utils.Error(w, http.StatusTooManyRequests, errors.New("Too many login attempts"))
+}
+
+// setRetryAfter - Retry-After in whole seconds, rounded up
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	if wait <= 0 {
+		return
+	}
+	w.Header().Set("Retry-After", fmt.Sprintf("%.0f", math.Ceil(wait.Seconds())))
+}
+
+// restoreOnLogin - logging in to an account deleted within the restore
+// window brings it back, one deleted longer ago can't log in. Every login
+// path goes through it.
+func restoreOnLogin(r *http.Request, db *sql.DB, userID uint64, deactivatedAt *time.Time) (int, error) {
+	if deactivatedAt == nil {
+		return 0, nil
+	}
+	if time.Since(*deactivatedAt) > config.AccountRestoreWindow {
+		return http.StatusUnauthorized, errors.New("Account deleted")
+	}
+	if err := repository.NewUserRepo(db).Restore(r.Context(), userID); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	audit.Record(r, db, models.AuditEntry{
+		ActorID:    userID,
+		Action:     audit.UserRestored,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(userID, 10),
+	})
+	return 0, nil
+}
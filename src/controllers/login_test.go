@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"api/src/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRestoreOnLoginWindow(t *testing.T) {
+	previous := config.AccountRestoreWindow
+	t.Cleanup(func() { config.AccountRestoreWindow = previous })
+	config.AccountRestoreWindow = 30 * 24 * time.Hour
+
+	expired := time.Now().Add(-31 * 24 * time.Hour)
+	tests := []struct {
+		name          string
+		deactivatedAt *time.Time
+		wantStatus    int
+		wantErr       bool
+	}{
+		{"active account", nil, 0, false},
+		{"deleted past the window", &expired, http.StatusUnauthorized, true},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/login", nil)
+		// neither case reaches the database
+		status, err := restoreOnLogin(r, nil, 1, test.deactivatedAt)
+		if status != test.wantStatus || (err != nil) != test.wantErr {
+			t.Errorf("%s: restoreOnLogin = %d, %v, want %d, error %v", test.name, status, err, test.wantStatus, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/database"
+	"api/src/models"
+	"api/src/repository"
+	"api/src/utils"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateConversation - start a conversation with one or more users, a
+// one-to-one conversation that already exists is reused
+func CreateConversation(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		utils.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	var request models.NewConversation
+	if err = json.Unmarshal(body, &request); err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	if err = request.Prepare(userID); err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	message := models.Message{SenderID: userID, Body: request.Body}
+	if message.Body != "" {
+		if err = message.Prepare(); err != nil {
+			utils.Error(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	messageRepo := repository.NewMessageRepo(db)
+	accepts, err := messageRepo.Recipients(r.Context(), userID, request.MemberIDs)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, memberID := range request.MemberIDs {
+		ok, found := accepts[memberID]
+		if !found {
+			utils.Error(w, http.StatusNotFound, errors.New("User not found"))
+			return
+		}
+		if !ok {
+			utils.Error(w, http.StatusForbidden, errors.New("User only accepts messages from people they follow"))
+			return
+		}
+	}
+
+	status := http.StatusCreated
+	var conversationID uint64
+	if len(request.MemberIDs) == 1 {
+		if conversationID, err = messageRepo.FindOneToOne(r.Context(), userID, request.MemberIDs[0]); err != nil {
+			utils.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if conversationID != 0 {
+			status = http.StatusOK
+		}
+	}
+	if conversationID == 0 {
+		conversationID, err = messageRepo.CreateConversation(r.Context(), append([]uint64{userID}, request.MemberIDs...))
+		if err != nil {
+			utils.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	if message.Body != "" {
+		message.ConversationID = conversationID
+		if _, err = messageRepo.CreateMessage(r.Context(), message); err != nil {
+			utils.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	conversation, err := messageRepo.FindConversation(r.Context(), conversationID, userID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, status, conversation)
+}
+
+// GetConversations - a page of the token's user conversations with their
+// last message and unread count
+func GetConversations(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	page, limit, err := pagination(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	conversations, err := repository.NewMessageRepo(db).FindConversations(r.Context(), userID, page, limit)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, conversations)
+}
+
+// GetMessages - a page of a conversation's history, newest first
+func GetMessages(w http.ResponseWriter, r *http.Request) {
+	page, limit, err := pagination(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	messageRepo := repository.NewMessageRepo(db)
+	conversationID, userID, status, err := conversationMember(r, messageRepo)
+	if err != nil {
+		utils.Error(w, status, err)
+		return
+	}
+	messages, err := messageRepo.FindMessages(r.Context(), conversationID, userID, page, limit)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, messages)
+}
+
+// SendMessage - post a message to a conversation
+func SendMessage(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		utils.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	var message models.Message
+	if err = json.Unmarshal(body, &message); err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	if err = message.Prepare(); err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	messageRepo := repository.NewMessageRepo(db)
+	conversationID, userID, status, err := conversationMember(r, messageRepo)
+	if err != nil {
+		utils.Error(w, status, err)
+		return
+	}
+
+	// settings may have changed since the conversation started
+	members, err := messageRepo.Members(r.Context(), conversationID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	var recipients []uint64
+	for _, member := range members {
+		if member.UserID != userID {
+			recipients = append(recipients, member.UserID)
+		}
+	}
+	accepts, err := messageRepo.Recipients(r.Context(), userID, recipients)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, ok := range accepts {
+		if !ok {
+			utils.Error(w, http.StatusForbidden, errors.New("User only accepts messages from people they follow"))
+			return
+		}
+	}
+
+	message.ConversationID = conversationID
+	message.SenderID = userID
+	if message.ID, err = messageRepo.CreateMessage(r.Context(), message); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusCreated, message)
+}
+
+// ReadConversation - move the read receipt up to message_id, or to the
+// latest message when the body is empty
+func ReadConversation(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		utils.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	var receipt struct {
+		MessageID uint64 `json:"message_id"`
+	}
+	if len(body) > 0 {
+		if err = json.Unmarshal(body, &receipt); err != nil {
+			utils.Error(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	messageRepo := repository.NewMessageRepo(db)
+	conversationID, userID, status, err := conversationMember(r, messageRepo)
+	if err != nil {
+		utils.Error(w, status, err)
+		return
+	}
+	if err = messageRepo.MarkRead(r.Context(), conversationID, userID, receipt.MessageID); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusNoContent, nil)
+}
+
+// DeleteMessage - delete a message for the token's user only
+func DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	messageID, err := strconv.ParseUint(mux.Vars(r)["messageID"], 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	messageRepo := repository.NewMessageRepo(db)
+	conversationID, userID, status, err := conversationMember(r, messageRepo)
+	if err != nil {
+		utils.Error(w, status, err)
+		return
+	}
+	found, err := messageRepo.DeleteForSelf(r.Context(), conversationID, userID, messageID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !found {
+		utils.Error(w, http.StatusNotFound, errors.New("Message not found"))
+		return
+	}
+	utils.JSON(w, http.StatusNoContent, nil)
+}
+
+// GetMessagingSettings - who may message an user
+func GetMessagingSettings(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	// Verify userID params with userID from token
+	userIDToken, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	if userIDToken != userID {
+		utils.Error(w, http.StatusForbidden, errors.New("User unauthorized"))
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	settings, err := repository.NewUserRepo(db).FindMessagingSettings(r.Context(), userID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, settings)
+}
+
+// UpdateMessagingSettings - change who may message an user
+func UpdateMessagingSettings(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	// Verify userID params with userID from token
+	userIDToken, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	if userIDToken != userID {
+		utils.Error(w, http.StatusForbidden, errors.New("User unauthorized"))
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		utils.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	var settings models.MessagingSettings
+	if err = json.Unmarshal(body, &settings); err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	if err = repository.NewUserRepo(db).UpdateMessagingSettings(r.Context(), userID, settings); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, settings)
+}
+
+// conversationMember - the conversation in the URI and the token's user,
+// with the status to answer when the user isn't one of its members
+func conversationMember(r *http.Request, messageRepo *repository.MessageRepo) (uint64, uint64, int, error) {
+	conversationID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, 0, http.StatusBadRequest, err
+	}
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		return 0, 0, http.StatusUnauthorized, err
+	}
+	member, err := messageRepo.IsMember(r.Context(), conversationID, userID)
+	if err != nil {
+		return 0, 0, http.StatusInternalServerError, err
+	}
+	if !member {
+		// don't tell outsiders which conversations exist
+		return 0, 0, http.StatusNotFound, errors.New("Conversation not found")
+	}
+	return conversationID, userID, 0, nil
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"api/src/authentication"
+	"api/src/database"
+	"api/src/repository"
+	"api/src/utils"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// GetNotifications - a page of the token's user notifications
+func GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	page, limit, err := pagination(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	notifications, err := repository.NewNotificationRepo(db).FindByUser(r.Context(), userID, page, limit)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, notifications)
+}
+
+// ReadNotifications - mark the token's user notifications as read
+func ReadNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	if err = repository.NewNotificationRepo(db).MarkRead(r.Context(), userID); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusNoContent, nil)
+}
+
+// pagination - page (from 0) and limit (1 to 100, default 20) query params
+func pagination(r *http.Request) (int, int, error) {
+	page, limit := 0, 20
+	var err error
+	query := r.URL.Query()
+	if value := query.Get("page"); value != "" {
+		if page, err = strconv.Atoi(value); err != nil || page < 0 {
+			return 0, 0, errors.New("page: invalid arguments")
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > 100 {
+			return 0, 0, errors.New("limit: must be between 1 and 100")
+		}
+	}
+	return page, limit, nil
+}
@@ -0,0 +1,510 @@
+package controllers
+
+import (
+	"api/src/audit"
+	"api/src/authentication"
+	"api/src/config"
+	"api/src/database"
+	"api/src/models"
+	"api/src/repository"
+	"api/src/utils"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const oauthCodeLifetime = 10 * time.Minute
+
+// oauthError - error response of the token, introspection and revocation
+// endpoints (RFC 6749 section 5.2)
+func oauthError(w http.ResponseWriter, statusCode int, code string, description string) {
+	utils.JSON(w, statusCode, struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+	}{code, description})
+}
+
+// RegisterOAuthClient - register a third-party application owned by the
+// token's user. The client secret is only ever returned in this response.
+func RegisterOAuthClient(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		utils.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	var client models.OAuthClient
+	if err = json.Unmarshal(body, &client); err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	if err = client.Prepare(); err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	id := make([]byte, 16)
+	if _, err = rand.Read(id); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	client.ID = hex.EncodeToString(id)
+	client.OwnerID = userID
+	if client.Confidential {
+		client.Secret, client.SecretHash, err = authentication.NewSecret("ocs_")
+		if err != nil {
+			utils.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	if err = repository.NewOAuthRepo(db).CreateClient(r.Context(), client); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	audit.Record(r, db, models.AuditEntry{
+		Action:     audit.OAuthClientCreate,
+		TargetType: "oauth_client",
+		TargetID:   client.ID,
+	})
+	utils.JSON(w, http.StatusCreated, client)
+}
+
+// GetOAuthClients - list the clients registered by the token's user
+func GetOAuthClients(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	clients, err := repository.NewOAuthRepo(db).FindClientsByOwner(r.Context(), userID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, clients)
+}
+
+// DeleteOAuthClient - remove a client and every token issued to it
+func DeleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	deleted, err := repository.NewOAuthRepo(db).DeleteClient(r.Context(), userID, mux.Vars(r)["id"])
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !deleted {
+		utils.Error(w, http.StatusNotFound, errors.New("Client not found"))
+		return
+	}
+	audit.Record(r, db, models.AuditEntry{
+		Action:     audit.OAuthClientDelete,
+		TargetType: "oauth_client",
+		TargetID:   mux.Vars(r)["id"],
+	})
+	utils.JSON(w, http.StatusNoContent, nil)
+}
+
+// authorizationRequest - parameters of an authorization code request
+type authorizationRequest struct {
+	client        models.OAuthClient
+	redirectURI   string
+	scopes        []string
+	state         string
+	codeChallenge string
+}
+
+// parseAuthorization - validate the authorization request in r.Form
+func parseAuthorization(r *http.Request, oauthRepo *repository.OAuthRepo) (authorizationRequest, error) {
+	if err := r.ParseForm(); err != nil {
+		return authorizationRequest{}, err
+	}
+	request := authorizationRequest{
+		redirectURI:   r.Form.Get("redirect_uri"),
+		scopes:        strings.Fields(r.Form.Get("scope")),
+		state:         r.Form.Get("state"),
+		codeChallenge: r.Form.Get("code_challenge"),
+	}
+	if r.Form.Get("response_type") != "code" {
+		return authorizationRequest{}, errors.New("response_type must be code")
+	}
+	client, err := oauthRepo.FindClient(r.Context(), r.Form.Get("client_id"))
+	if err != nil {
+		return authorizationRequest{}, err
+	}
+	if client.ID == "" {
+		return authorizationRequest{}, errors.New("Unknown client_id")
+	}
+	if !client.AllowsRedirect(request.redirectURI) {
+		return authorizationRequest{}, errors.New("redirect_uri is not registered for this client")
+	}
+	if len(request.scopes) == 0 || !client.AllowsScopes(request.scopes) {
+		return authorizationRequest{}, errors.New("scope is missing or not allowed for this client")
+	}
+	if request.codeChallenge == "" || r.Form.Get("code_challenge_method") != "S256" {
+		return authorizationRequest{}, errors.New("PKCE with code_challenge_method S256 is required")
+	}
+	request.client = client
+	return request, nil
+}
+
+// GetAuthorization - what the consent screen shows: the client and the
+// scopes it asks the token's user for
+func GetAuthorization(w http.ResponseWriter, r *http.Request) {
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	request, err := parseAuthorization(r, repository.NewOAuthRepo(db))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, struct {
+		ClientID    string   `json:"client_id"`
+		ClientName  string   `json:"client_name"`
+		RedirectURI string   `json:"redirect_uri"`
+		Scopes      []string `json:"scopes"`
+		State       string   `json:"state,omitempty"`
+	}{
+		ClientID:    request.client.ID,
+		ClientName:  request.client.Name,
+		RedirectURI: request.redirectURI,
+		Scopes:      request.scopes,
+		State:       request.state,
+	})
+}
+
+// Authorize - record the token's user consent decision and return where
+// to redirect the browser, with a code or access_denied
+func Authorize(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	oauthRepo := repository.NewOAuthRepo(db)
+	request, err := parseAuthorization(r, oauthRepo)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	query := url.Values{}
+	if request.state != "" {
+		query.Set("state", request.state)
+	}
+	if r.Form.Get("approve") != "true" {
+		query.Set("error", "access_denied")
+	} else {
+		code, codeHash, err := authentication.NewSecret("")
+		if err != nil {
+			utils.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if err = oauthRepo.CreateCode(r.Context(), models.OAuthCode{
+			Hash:          codeHash,
+			ClientID:      request.client.ID,
+			UserID:        userID,
+			RedirectURI:   request.redirectURI,
+			Scopes:        request.scopes,
+			CodeChallenge: request.codeChallenge,
+			ExpiresAt:     time.Now().Add(oauthCodeLifetime),
+		}); err != nil {
+			utils.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		audit.Record(r, db, models.AuditEntry{
+			ActorID:    userID,
+			Action:     audit.OAuthConsent,
+			TargetType: "oauth_client",
+			TargetID:   request.client.ID,
+			Changes:    scopeChanges(request.scopes),
+		})
+		query.Set("code", code)
+	}
+
+	separator := "?"
+	if strings.Contains(request.redirectURI, "?") {
+		separator = "&"
+	}
+	utils.JSON(w, http.StatusOK, struct {
+		RedirectURI string `json:"redirect_uri"`
+	}{request.redirectURI + separator + query.Encode()})
+}
+
+// OAuthToken - token endpoint for the authorization_code and
+// client_credentials grants
+func OAuthToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	oauthRepo := repository.NewOAuthRepo(db)
+	client, authenticated, err := oauthClient(r, oauthRepo)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if client.ID == "" || (client.Confidential && !authenticated) {
+		oauthError(w, http.StatusUnauthorized, "invalid_client", "")
+		return
+	}
+
+	token := models.OAuthToken{
+		ClientID:  client.ID,
+		ExpiresAt: time.Now().Add(config.OAuthTokenLifetime),
+	}
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		code, err := oauthRepo.TakeCode(r.Context(), authentication.HashAccessToken(r.PostForm.Get("code")))
+		if err != nil {
+			utils.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		if code.Hash == "" || code.ClientID != client.ID || time.Now().After(code.ExpiresAt) ||
+			code.RedirectURI != r.PostForm.Get("redirect_uri") {
+			oauthError(w, http.StatusBadRequest, "invalid_grant", "")
+			return
+		}
+		if !verifierMatches(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+			oauthError(w, http.StatusBadRequest, "invalid_grant", "code_verifier does not match")
+			return
+		}
+		token.UserID = code.UserID
+		token.Scopes = code.Scopes
+
+	case "client_credentials":
+		if !client.Confidential {
+			oauthError(w, http.StatusUnauthorized, "unauthorized_client", "")
+			return
+		}
+		token.Scopes = client.Scopes
+		if requested := strings.Fields(r.PostForm.Get("scope")); len(requested) > 0 {
+			if !client.AllowsScopes(requested) {
+				oauthError(w, http.StatusBadRequest, "invalid_scope", "")
+				return
+			}
+			token.Scopes = requested
+		}
+
+	default:
+		oauthError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+		return
+	}
+
+	accessToken, tokenHash, err := authentication.NewOAuthToken()
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	token.Hash = tokenHash
+	if err = oauthRepo.CreateToken(r.Context(), token); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	// the client is the caller, the user it acts for is the actor
+	audit.Record(r, db, models.AuditEntry{
+		ActorID:    token.UserID,
+		Action:     audit.OAuthTokenIssued,
+		TargetType: "oauth_client",
+		TargetID:   client.ID,
+		Changes:    scopeChanges(token.Scopes),
+	})
+	w.Header().Set("Cache-Control", "no-store")
+	utils.JSON(w, http.StatusOK, struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+		Scope       string `json:"scope"`
+	}{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(config.OAuthTokenLifetime.Seconds()),
+		Scope:       strings.Join(token.Scopes, " "),
+	})
+}
+
+// IntrospectOAuthToken - token introspection for confidential clients
+// (RFC 7662)
+func IntrospectOAuthToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	oauthRepo := repository.NewOAuthRepo(db)
+	client, authenticated, err := oauthClient(r, oauthRepo)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !client.Confidential || !authenticated {
+		oauthError(w, http.StatusUnauthorized, "invalid_client", "")
+		return
+	}
+
+	token, err := oauthRepo.FindToken(r.Context(), authentication.HashAccessToken(r.PostForm.Get("token")))
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !token.Active(time.Now()) {
+		utils.JSON(w, http.StatusOK, struct {
+			Active bool `json:"active"`
+		}{false})
+		return
+	}
+	introspection := struct {
+		Active    bool   `json:"active"`
+		Scope     string `json:"scope"`
+		ClientID  string `json:"client_id"`
+		TokenType string `json:"token_type"`
+		Expires   int64  `json:"exp"`
+		Subject   uint64 `json:"sub,omitempty"`
+	}{
+		Active:    true,
+		Scope:     strings.Join(token.Scopes, " "),
+		ClientID:  token.ClientID,
+		TokenType: "Bearer",
+		Expires:   token.ExpiresAt.Unix(),
+		Subject:   token.UserID,
+	}
+	utils.JSON(w, http.StatusOK, introspection)
+}
+
+// RevokeOAuthToken - a client revokes one of its tokens (RFC 7009)
+func RevokeOAuthToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	oauthRepo := repository.NewOAuthRepo(db)
+	client, authenticated, err := oauthClient(r, oauthRepo)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if client.ID == "" || (client.Confidential && !authenticated) {
+		oauthError(w, http.StatusUnauthorized, "invalid_client", "")
+		return
+	}
+	tokenHash := authentication.HashAccessToken(r.PostForm.Get("token"))
+	if err = oauthRepo.RevokeToken(r.Context(), client.ID, tokenHash); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	audit.Record(r, db, models.AuditEntry{
+		Action:     audit.OAuthRevoke,
+		TargetType: "oauth_client",
+		TargetID:   client.ID,
+	})
+	// unknown tokens are not an error for the client
+	w.WriteHeader(http.StatusOK)
+}
+
+// oauthClient - the calling client from HTTP Basic or client_id and
+// client_secret form fields; authenticated when the secret matched
+func oauthClient(r *http.Request, oauthRepo *repository.OAuthRepo) (models.OAuthClient, bool, error) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	client, err := oauthRepo.FindClient(r.Context(), clientID)
+	if err != nil || client.ID == "" {
+		return models.OAuthClient{}, false, err
+	}
+	authenticated := client.Confidential && secret != "" && subtle.ConstantTimeCompare(
+		[]byte(authentication.HashAccessToken(secret)), []byte(client.SecretHash),
+	) == 1
+	return client, authenticated, nil
+}
+
+// verifierMatches - whether the PKCE code_verifier hashes to the S256
+// challenge sent with the authorization request
+func verifierMatches(verifier string, challenge string) bool {
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// scopeChanges - the scopes granted, for the audit entry
+func scopeChanges(scopes []string) json.RawMessage {
+	data, err := json.Marshal(map[string]models.Change{
+		"scopes": {After: strings.Join(scopes, " ")},
+	})
+	if err != nil {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,28 @@
+package controllers
+
+import "testing"
+
+func TestVerifierMatches(t *testing.T) {
+	// BASE64URL(SHA256(verifier)) without padding
+	const verifier = "dBjftJeZ4CVP-mJ92K9qzJqs4hmGgX3x0eptuiA7S8I"
+	const challenge = "3ohEnVi5-6kPRJFzF4EuC9hLOom8HAWnxKwErNm2VuM"
+
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{"matching verifier", verifier, challenge, true},
+		{"wrong verifier", verifier + "x", challenge, false},
+		{"missing verifier", "", challenge, false},
+		{"plain challenge", verifier, verifier, false},
+		{"padded challenge", verifier, challenge + "=", false},
+		{"missing challenge", verifier, "", false},
+	}
+	for _, test := range tests {
+		if got := verifierMatches(test.verifier, test.challenge); got != test.want {
+			t.Errorf("%s: verifierMatches = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
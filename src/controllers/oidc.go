@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"api/src/audit"
+	"api/src/authentication"
+	"api/src/database"
+	"api/src/models"
+	"api/src/oidc"
+	"api/src/repository"
+	"api/src/utils"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+)
+
+const oidcCallbackPath = "/login/oidc/callback"
+
+// errProviderLogin - what the client learns when the provider refused the
+// login or answered something unexpected, the details are only logged
+var errProviderLogin = errors.New("Identity provider login failed")
+
+// unusablePassword - stored for users created through a provider, no
+// password hash ever matches it
+const unusablePassword = "!"
+
+// OIDCLogin - send the browser to the identity provider
+func OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider, err := oidc.Default()
+	if err != nil {
+		utils.Error(w, http.StatusNotFound, err)
+		return
+	}
+	// browser clients start with ?cookie=true to get the token as cookies
+	flow, err := oidc.NewFlow(r.URL.Query().Get("cookie") == "true")
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	redirect, err := provider.AuthCodeURL(r.Context(), flow)
+	if err != nil {
+		log.Printf("oidc login: %v", err)
+		utils.Error(w, http.StatusBadGateway, errProviderLogin)
+		return
+	}
+	flow.SetCookie(w, oidcCallbackPath)
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+// OIDCCallback - finish the provider login and issue our own token
+func OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	provider, err := oidc.Default()
+	if err != nil {
+		utils.Error(w, http.StatusNotFound, err)
+		return
+	}
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		log.Printf("oidc callback: provider error %q: %s", providerErr, query.Get("error_description"))
+		utils.Error(w, http.StatusUnauthorized, errProviderLogin)
+		return
+	}
+	flow, err := oidc.FlowFromCookie(r, query.Get("state"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	oidc.ClearCookie(w, oidcCallbackPath)
+
+	idToken, err := provider.Exchange(r.Context(), query.Get("code"), flow)
+	if err != nil {
+		log.Printf("oidc callback: %v", err)
+		utils.Error(w, http.StatusBadGateway, errProviderLogin)
+		return
+	}
+	claims, err := provider.VerifyIDToken(r.Context(), idToken, flow.Nonce)
+	if err != nil {
+		log.Printf("oidc callback: %v", err)
+		utils.Error(w, http.StatusUnauthorized, errProviderLogin)
+		return
+	}
+
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	userID, err := oidcUser(r.Context(), db, provider.Issuer, claims)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	deactivatedAt, err := repository.NewUserRepo(db).FindDeactivatedAt(r.Context(), userID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if status, err := restoreOnLogin(r, db, userID, deactivatedAt); err != nil {
+		utils.Error(w, status, err)
+		return
+	}
+	session, err := authentication.NewSession(r, userID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	token, err := authentication.Token(userID, session.ID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	audit.Record(r, db, models.AuditEntry{
+		ActorID:    userID,
+		Action:     audit.Login,
+		TargetType: "session",
+		TargetID:   session.ID,
+	})
+	writeToken(w, token, flow.Cookie)
+}
+
+// oidcUser - the user linked to the provider account; otherwise link the
+// user owning the same verified email, or create a new one. We never
+// verified local emails, so whoever signed up with that address first
+// may not be its owner: the linked account loses its password, sessions
+// and tokens, and only the provider login reaches it from then on.
+func oidcUser(ctx context.Context, db *sql.DB, issuer string, claims oidc.Claims) (uint64, error) {
+	identityRepo := repository.NewIdentityRepo(db)
+	userID, err := identityRepo.FindUser(ctx, issuer, claims.Subject)
+	if err != nil || userID != 0 {
+		return userID, err
+	}
+
+	userRepo := repository.NewUserRepo(db)
+	if claims.Email != "" && claims.EmailVerified {
+		existing, err := userRepo.FindByEmail(ctx, claims.Email)
+		if err != nil {
+			return 0, err
+		}
+		userID = existing.ID
+		if userID != 0 && existing.Password != unusablePassword {
+			if err = userRepo.ResetCredentials(ctx, userID, unusablePassword); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if userID == 0 {
+		if claims.Email == "" || !claims.EmailVerified {
+			return 0, errors.New("oidc: provider did not return a verified email")
+		}
+		nick, err := generateNick(ctx, userRepo, claims)
+		if err != nil {
+			return 0, err
+		}
+		name := claims.Name
+		if name == "" {
+			name = nick
+		}
+		userID, err = userRepo.Create(ctx, models.User{
+			Name:     name,
+			Nick:     nick,
+			Email:    claims.Email,
+			Password: unusablePassword,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return userID, identityRepo.Link(ctx, issuer, claims.Subject, userID)
+}
+
+// generateNick - a free nick from the preferred username or the email,
+// with a numeric suffix when taken
+func generateNick(ctx context.Context, userRepo *repository.UserRepo, claims oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base = strings.Split(claims.Email, "@")[0]
+	}
+	base = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return -1
+	}, base)
+	if base == "" {
+		base = "user"
+	}
+	if len(base) > 45 {
+		base = base[:45]
+	}
+
+	nick := base
+	for attempt := 0; attempt < 10; attempt++ {
+		taken, err := userRepo.NickExists(ctx, nick)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return nick, nil
+		}
+		suffix, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			return "", err
+		}
+		nick = fmt.Sprintf("%s%04d", base, suffix.Int64())
+	}
+	return "", errors.New("oidc: could not generate a free nick")
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"api/src/audit"
+	"api/src/authentication"
+	"api/src/database"
+	"api/src/models"
+	"api/src/repository"
+	"api/src/utils"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// GetSessions - list where the token's user is logged in
+func GetSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	sessionRepo := repository.NewSessionRepo(db)
+	sessions, err := sessionRepo.FindByUser(r.Context(), userID, time.Now().Add(-authentication.TokenLifetime))
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	current := authentication.SessionID(r)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+	utils.JSON(w, http.StatusOK, sessions)
+}
+
+// DeleteSession - log out one of the token's user sessions
+func DeleteSession(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	sessionID := mux.Vars(r)["id"]
+
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	sessionRepo := repository.NewSessionRepo(db)
+	revoked, err := sessionRepo.Revoke(r.Context(), userID, sessionID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !revoked {
+		utils.Error(w, http.StatusNotFound, errors.New("Session not found"))
+		return
+	}
+	audit.Record(r, db, models.AuditEntry{
+		Action:     audit.SessionRevoked,
+		TargetType: "session",
+		TargetID:   sessionID,
+	})
+	utils.JSON(w, http.StatusNoContent, nil)
+}
+
+// DeleteSessions - log the token's user out everywhere
+func DeleteSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	sessionRepo := repository.NewSessionRepo(db)
+	if err = sessionRepo.RevokeAll(r.Context(), userID); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	audit.Record(r, db, models.AuditEntry{
+		Action:     audit.SessionRevoked,
+		TargetType: "session",
+		TargetID:   "*",
+	})
+	utils.JSON(w, http.StatusNoContent, nil)
+}
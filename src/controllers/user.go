@@ -1,8 +1,10 @@
 package controllers
 
 import (
+	"api/src/audit"
 	"api/src/authentication"
 	"api/src/database"
+	"api/src/hash"
 	"api/src/models"
 	"api/src/repository"
 	"api/src/utils"
@@ -33,7 +35,7 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 		utils.Error(w, http.StatusBadRequest, error)
 		return
 	}
-	db, error := database.Connect()
+	db, error := database.Connect(r.Context())
 	if error != nil {
 		utils.Error(w, http.StatusInternalServerError, error)
 		return
@@ -41,11 +43,17 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 	defer db.Close()
 
 	userRepo := repository.NewUserRepo(db)
-	user.ID, error = userRepo.Create(user)
+	user.ID, error = userRepo.Create(r.Context(), user)
 	if error != nil {
 		utils.Error(w, http.StatusInternalServerError, error)
 		return
 	}
+	audit.Record(r, db, models.AuditEntry{
+		ActorID:    user.ID,
+		Action:     audit.UserCreated,
+		TargetType: "user",
+		TargetID:   strconv.FormatUint(user.ID, 10),
+	})
 	utils.JSON(w, http.StatusCreated, user)
 }
 
@@ -53,7 +61,7 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 func GetUsers(w http.ResponseWriter, r *http.Request) {
 	nameOrNick := strings.ToLower(r.URL.Query().Get("user"))
 
-	db, error := database.Connect()
+	db, error := database.Connect(r.Context())
 	if error != nil {
 		utils.Error(w, http.StatusInternalServerError, error)
 		return
@@ -61,7 +69,7 @@ func GetUsers(w http.ResponseWriter, r *http.Request) {
 	defer db.Close()
 
 	userRepo := repository.NewUserRepo(db)
-	users, error := userRepo.Find(nameOrNick)
+	users, error := userRepo.Find(r.Context(), nameOrNick)
 	if error != nil {
 		utils.Error(w, http.StatusInternalServerError, error)
 		return
@@ -89,14 +97,15 @@ func GetUser(w http.ResponseWriter, r *http.Request) {
 		utils.Error(w, http.StatusForbidden, errors.New("User unauthorized"))
 		return
 	}
-	db, err := database.Connect()
+	db, err := database.Connect(r.Context())
 	if err != nil {
 		utils.Error(w, http.StatusInternalServerError, err)
+		return
 	}
 	defer db.Close()
 
 	userRepo := repository.NewUserRepo(db)
-	user, err := userRepo.FindById(userID)
+	user, err := userRepo.FindById(r.Context(), userID)
 	if err != nil {
 		utils.Error(w, http.StatusNotFound, err)
 		return
@@ -122,16 +131,28 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
 		utils.Error(w, http.StatusForbidden, errors.New("User unauthorized"))
 		return
 	}
-	db, err := database.Connect()
+	db, err := database.Connect(r.Context())
 	if err != nil {
 		utils.Error(w, http.StatusInternalServerError, err)
+		return
 	}
 	defer db.Close()
 
 	userRepo := repository.NewUserRepo(db)
-	if err := userRepo.Delete(userID); err != nil {
+	if err := userRepo.Delete(r.Context(), userID); err != nil {
 		utils.Error(w, http.StatusInternalServerError, err)
+		return
 	}
+	// logging in again within the restore window brings the account back
+	if err := repository.NewSessionRepo(db).RevokeAll(r.Context(), userID); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	audit.Record(r, db, models.AuditEntry{
+		Action:     audit.UserDeleted,
+		TargetType: "user",
+		TargetID:   params["id"],
+	})
 	utils.JSON(w, http.StatusNoContent, nil)
 }
 
@@ -169,7 +190,7 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 		utils.Error(w, http.StatusBadRequest, err)
 		return
 	}
-	db, error := database.Connect()
+	db, error := database.Connect(r.Context())
 	if error != nil {
 		utils.Error(w, http.StatusInternalServerError, error)
 		return
@@ -177,11 +198,101 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 	defer db.Close()
 
 	userRepo := repository.NewUserRepo(db)
-	if err = userRepo.Update(userID, user); err != nil {
-		utils.Error(w, http.StatusInternalServerError, error)
+	before, err := userRepo.FindById(r.Context(), userID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err = userRepo.Update(r.Context(), userID, user); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	audit.Record(r, db, models.AuditEntry{
+		Action:     audit.UserUpdated,
+		TargetType: "user",
+		TargetID:   params["id"],
+		Changes:    audit.UserChanges(before, user),
+	})
+
+	utils.JSON(w, http.StatusNoContent, nil)
+}
+
+// UpdatePassword - change the password of the token's user
+func UpdatePassword(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	userID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	// Verify userID params with userID from token
+	userIDToken, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	if userIDToken != userID {
+		utils.Error(w, http.StatusForbidden, errors.New("User unauthorized"))
 		return
 	}
 
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		utils.Error(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	var password models.Password
+	if err = json.Unmarshal(body, &password); err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	if err = password.Prepare(); err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	userRepo := repository.NewUserRepo(db)
+	user, err := userRepo.FindById(r.Context(), userID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	currentHash, err := userRepo.FindPassword(r.Context(), userID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	// only someone who knows the current password learns about the policy
+	// and the breach corpus
+	if _, err = hash.Verify(password.Current, currentHash); err != nil {
+		utils.Error(w, http.StatusUnauthorized, errors.New("Current: password does not match"))
+		return
+	}
+	if err = password.Check(user); err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	newHash, err := hash.Hash(password.New)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if err = userRepo.UpdatePassword(r.Context(), userID, string(newHash)); err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	audit.Record(r, db, models.AuditEntry{
+		Action:     audit.PasswordChange,
+		TargetType: "user",
+		TargetID:   params["id"],
+	})
 	utils.JSON(w, http.StatusNoContent, nil)
 }
 
@@ -204,7 +315,7 @@ func FollowUser(w http.ResponseWriter, r *http.Request) {
 		utils.Error(w, http.StatusForbidden, errors.New("Not possible follow yourself"))
 		return
 	}
-	db, err := database.Connect()
+	db, err := database.Connect(r.Context())
 	if err != nil {
 		utils.Error(w, http.StatusInternalServerError, err)
 		return
@@ -212,10 +323,20 @@ func FollowUser(w http.ResponseWriter, r *http.Request) {
 	defer db.Close()
 
 	userRepo := repository.NewUserRepo(db)
-	if err := userRepo.FollowUser(follower_id, user_id); err != nil {
+	found, err := userRepo.FollowUser(r.Context(), follower_id, user_id)
+	if err != nil {
 		utils.Error(w, http.StatusInternalServerError, err)
 		return
 	}
+	if !found {
+		utils.Error(w, http.StatusNotFound, errors.New("User not found"))
+		return
+	}
+	audit.Record(r, db, models.AuditEntry{
+		Action:     audit.Follow,
+		TargetType: "user",
+		TargetID:   params["id"],
+	})
 	utils.JSON(w, http.StatusNoContent, nil)
 }
 
@@ -238,7 +359,7 @@ func UnFollowUser(w http.ResponseWriter, r *http.Request) {
 		utils.Error(w, http.StatusForbidden, errors.New("Not possible unfollow yourself"))
 		return
 	}
-	db, err := database.Connect()
+	db, err := database.Connect(r.Context())
 	if err != nil {
 		utils.Error(w, http.StatusInternalServerError, err)
 		return
@@ -246,10 +367,15 @@ func UnFollowUser(w http.ResponseWriter, r *http.Request) {
 	defer db.Close()
 
 	userRepo := repository.NewUserRepo(db)
-	if err := userRepo.UnFollowUser(follower_id, user_id); err != nil {
+	if err := userRepo.UnFollowUser(r.Context(), follower_id, user_id); err != nil {
 		utils.Error(w, http.StatusInternalServerError, err)
 		return
 	}
+	audit.Record(r, db, models.AuditEntry{
+		Action:     audit.Unfollow,
+		TargetType: "user",
+		TargetID:   params["id"],
+	})
 	utils.JSON(w, http.StatusNoContent, nil)
 }
 
@@ -262,7 +388,7 @@ func GetFollowers(w http.ResponseWriter, r *http.Request) {
 		utils.Error(w, http.StatusBadRequest, err)
 		return
 	}
-	db, err := database.Connect()
+	db, err := database.Connect(r.Context())
 	if err != nil {
 		utils.Error(w, http.StatusInternalServerError, err)
 		return
@@ -270,7 +396,7 @@ func GetFollowers(w http.ResponseWriter, r *http.Request) {
 	defer db.Close()
 
 	userRepo := repository.NewUserRepo(db)
-	followers, err := userRepo.GetFollowers(userID)
+	followers, err := userRepo.GetFollowers(r.Context(), userID)
 	if err != nil {
 		utils.Error(w, http.StatusInternalServerError, err)
 		return
@@ -287,7 +413,7 @@ func GetFollowing(w http.ResponseWriter, r *http.Request) {
 		utils.Error(w, http.StatusBadRequest, err)
 		return
 	}
-	db, err := database.Connect()
+	db, err := database.Connect(r.Context())
 	if err != nil {
 		utils.Error(w, http.StatusInternalServerError, err)
 		return
@@ -295,10 +421,109 @@ func GetFollowing(w http.ResponseWriter, r *http.Request) {
 	defer db.Close()
 
 	userRepo := repository.NewUserRepo(db)
-	followers, err := userRepo.GetFollowing(userID)
+	followers, err := userRepo.GetFollowing(r.Context(), userID)
 	if err != nil {
 		utils.Error(w, http.StatusInternalServerError, err)
 		return
 	}
 	utils.JSON(w, http.StatusOK, followers)
 }
+
+// GetMutuals - get users that follow an user and are followed back
+func GetMutuals(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	userID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	userRepo := repository.NewUserRepo(db)
+	mutuals, err := userRepo.GetMutuals(r.Context(), userID)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, mutuals)
+}
+
+// GetRelationship - how the token's user and an user follow each other
+func GetRelationship(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	userID, err := strconv.ParseUint(params["id"], 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	userIDToken, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	userRepo := repository.NewUserRepo(db)
+	relationships, err := userRepo.Relationships(r.Context(), userIDToken, []uint64{userID})
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(relationships) == 0 {
+		utils.Error(w, http.StatusNotFound, errors.New("User not found"))
+		return
+	}
+	utils.JSON(w, http.StatusOK, relationships[0])
+}
+
+// GetRelationships - GetRelationship for many users at once,
+// ?ids=1,2,3 with at most 100 ids. Unknown users are left out.
+func GetRelationships(w http.ResponseWriter, r *http.Request) {
+	var ids []uint64
+	for _, value := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			utils.Error(w, http.StatusBadRequest, errors.New("ids: invalid arguments"))
+			return
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 || len(ids) > 100 {
+		utils.Error(w, http.StatusBadRequest, errors.New("ids: between 1 and 100 ids"))
+		return
+	}
+	userIDToken, err := authentication.GetUserID(r)
+	if err != nil {
+		utils.Error(w, http.StatusUnauthorized, err)
+		return
+	}
+	db, err := database.Connect(r.Context())
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer db.Close()
+
+	userRepo := repository.NewUserRepo(db)
+	relationships, err := userRepo.Relationships(r.Context(), userIDToken, ids)
+	if err != nil {
+		utils.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, relationships)
+}
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGetRelationshipsRejectsIDs(t *testing.T) {
+	tooMany := make([]string, 101)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprint(i + 1)
+	}
+	tests := []struct {
+		name string
+		ids  string
+	}{
+		{"missing", ""},
+		{"only separators", ", ,"},
+		{"not a number", "1,two"},
+		{"negative", "-1"},
+		{"too many", strings.Join(tooMany, ",")},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/users/relationships?ids="+url.QueryEscape(test.ids), nil)
+		w := httptest.NewRecorder()
+		// rejected before looking at the token or the database
+		GetRelationships(w, r)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", test.name, w.Code, http.StatusBadRequest)
+		}
+	}
+}
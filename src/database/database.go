@@ -2,20 +2,38 @@ package database
 
 import (
 	"api/src/config"
+	"context"
 	"database/sql"
+	"sync"
 
 	_ "github.com/go-sql-driver/mysql" // Driver
 )
 
-func Connect() (*sql.DB, error) {
+var (
+	pool     *sql.DB
+	poolErr  error
+	openPool sync.Once
+)
+
+func Connect(ctx context.Context) (*sql.DB, error) {
 	db, error := sql.Open("mysql", config.SqlConfig)
 	if error != nil {
 		return nil, error
 	}
 
-	if error = db.Ping(); error != nil {
+	if error = db.PingContext(ctx); error != nil {
 		db.Close()
 		return nil, error
 	}
 	return db, nil
 }
+
+// Pool - a connection pool shared by the whole process, for code running on
+// every request where a connection per request costs more than the query.
+// Never close it.
+func Pool() (*sql.DB, error) {
+	openPool.Do(func() {
+		pool, poolErr = sql.Open("mysql", config.SqlConfig)
+	})
+	return pool, poolErr
+}
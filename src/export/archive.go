@@ -0,0 +1,371 @@
+package export
+
+import (
+	"api/src/models"
+	"api/src/repository"
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Build - write a zip with everything held about the user: profile,
+// followers, following, sessions, access tokens, OAuth clients and grants,
+// linked identities, notifications, conversations and messages, lists with
+// their members, list memberships and subscriptions, and audit entries,
+// each as JSON and as CSV
+func Build(ctx context.Context, db *sql.DB, userID uint64, w io.Writer) error {
+	userRepo := repository.NewUserRepo(db)
+	profile, err := userRepo.FindById(ctx, userID)
+	if err != nil {
+		return err
+	}
+	followers, err := userRepo.GetFollowers(ctx, userID)
+	if err != nil {
+		return err
+	}
+	following, err := userRepo.GetFollowing(ctx, userID)
+	if err != nil {
+		return err
+	}
+	sessions, err := repository.NewSessionRepo(db).FindByUser(ctx, userID, time.Time{})
+	if err != nil {
+		return err
+	}
+	tokens, err := repository.NewAccessTokenRepo(db).FindByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	oauthRepo := repository.NewOAuthRepo(db)
+	clients, err := oauthRepo.FindClientsByOwner(ctx, userID)
+	if err != nil {
+		return err
+	}
+	grants, err := oauthRepo.FindGrants(ctx, userID)
+	if err != nil {
+		return err
+	}
+	identities, err := repository.NewIdentityRepo(db).FindByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	notifications, err := repository.NewNotificationRepo(db).FindAllByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	messageRepo := repository.NewMessageRepo(db)
+	conversations, err := messageRepo.FindAllConversations(ctx, userID)
+	if err != nil {
+		return err
+	}
+	messages, err := messageRepo.FindAllMessages(ctx, userID)
+	if err != nil {
+		return err
+	}
+	listRepo := repository.NewListRepo(db)
+	lists, err := listRepo.FindByOwner(ctx, userID, true)
+	if err != nil {
+		return err
+	}
+	members := []listMember{}
+	for _, list := range lists {
+		users, err := listRepo.Members(ctx, list.ID)
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			members = append(members, listMember{ListID: list.ID, UserID: user.ID, Nick: user.Nick})
+		}
+	}
+	memberships, err := listRepo.FindMemberships(ctx, userID)
+	if err != nil {
+		return err
+	}
+	subscriptions, err := listRepo.FindSubscribed(ctx, userID)
+	if err != nil {
+		return err
+	}
+	var entries []models.AuditEntry
+	err = repository.NewAuditRepo(db).Each(ctx, models.AuditFilter{ActorID: userID}, func(entry models.AuditEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data interface{}
+		rows [][]string
+	}{
+		{"profile", profile, userRows([]models.User{profile})},
+		{"followers", related(followers), relatedRows(followers)},
+		{"following", related(following), relatedRows(following)},
+		{"sessions", sessions, sessionRows(sessions)},
+		{"access_tokens", tokens, accessTokenRows(tokens)},
+		{"oauth_clients", clients, oauthClientRows(clients)},
+		{"oauth_grants", grants, oauthGrantRows(grants)},
+		{"identities", identities, identityRows(identities)},
+		{"notifications", notifications, notificationRows(notifications)},
+		{"conversations", conversations, conversationRows(conversations)},
+		{"messages", messages, messageRows(messages)},
+		{"lists", lists, listRows(lists)},
+		{"list_members", members, listMemberRows(members)},
+		{"list_memberships", memberships, listRows(memberships)},
+		{"list_subscriptions", subscriptions, listRows(subscriptions)},
+		{"audit", entries, auditRows(entries)},
+	}
+	for _, file := range files {
+		if err = writeJSON(archive, file.name+".json", file.data); err != nil {
+			return err
+		}
+		if err = writeCSV(archive, file.name+".csv", file.rows); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func writeJSON(archive *zip.Writer, name string, data interface{}) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+func writeCSV(archive *zip.Writer, name string, rows [][]string) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(file)
+	if err = writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+func userRows(users []models.User) [][]string {
+	rows := [][]string{{"id", "name", "nick", "email", "created_at"}}
+	for _, user := range users {
+		rows = append(rows, []string{
+			strconv.FormatUint(user.ID, 10),
+			user.Name,
+			user.Nick,
+			user.Email,
+			user.CreateAt.Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+// relatedUser - another account the user follows or is followed by, only
+// what its profile shows to everyone
+type relatedUser struct {
+	ID   uint64 `json:"id"`
+	Nick string `json:"nick"`
+	Name string `json:"name"`
+}
+
+func related(users []models.User) []relatedUser {
+	related := []relatedUser{}
+	for _, user := range users {
+		related = append(related, relatedUser{ID: user.ID, Nick: user.Nick, Name: user.Name})
+	}
+	return related
+}
+
+func relatedRows(users []models.User) [][]string {
+	rows := [][]string{{"id", "nick", "name"}}
+	for _, user := range users {
+		rows = append(rows, []string{strconv.FormatUint(user.ID, 10), user.Nick, user.Name})
+	}
+	return rows
+}
+
+func sessionRows(sessions []models.Session) [][]string {
+	rows := [][]string{{"id", "user_agent", "ip", "created_at", "last_seen_at"}}
+	for _, session := range sessions {
+		rows = append(rows, []string{
+			session.ID,
+			session.UserAgent,
+			session.IP,
+			session.CreateAt.Format(time.RFC3339),
+			session.LastSeenAt.Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+func accessTokenRows(tokens []models.AccessToken) [][]string {
+	rows := [][]string{{"id", "name", "scopes", "expires_at", "last_used_at", "created_at"}}
+	for _, token := range tokens {
+		rows = append(rows, []string{
+			strconv.FormatUint(token.ID, 10),
+			token.Name,
+			strings.Join(token.Scopes, " "),
+			optionalTime(token.ExpiresAt),
+			optionalTime(token.LastUsedAt),
+			token.CreateAt.Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+func oauthClientRows(clients []models.OAuthClient) [][]string {
+	rows := [][]string{{"client_id", "name", "redirect_uris", "scopes", "confidential", "created_at"}}
+	for _, client := range clients {
+		rows = append(rows, []string{
+			client.ID,
+			client.Name,
+			strings.Join(client.RedirectURIs, " "),
+			strings.Join(client.Scopes, " "),
+			strconv.FormatBool(client.Confidential),
+			client.CreateAt.Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+func oauthGrantRows(grants []models.OAuthGrant) [][]string {
+	rows := [][]string{{"client_id", "client_name", "scopes", "expires_at", "revoked_at", "created_at"}}
+	for _, grant := range grants {
+		rows = append(rows, []string{
+			grant.ClientID,
+			grant.ClientName,
+			strings.Join(grant.Scopes, " "),
+			grant.ExpiresAt.Format(time.RFC3339),
+			optionalTime(grant.RevokedAt),
+			grant.CreateAt.Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+func identityRows(identities []models.Identity) [][]string {
+	rows := [][]string{{"issuer", "subject", "created_at"}}
+	for _, identity := range identities {
+		rows = append(rows, []string{
+			identity.Issuer,
+			identity.Subject,
+			identity.CreateAt.Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+func notificationRows(notifications []models.Notification) [][]string {
+	rows := [][]string{{"id", "kind", "message", "link", "read_at", "created_at"}}
+	for _, notification := range notifications {
+		rows = append(rows, []string{
+			strconv.FormatUint(notification.ID, 10),
+			notification.Kind,
+			notification.Message,
+			notification.Link,
+			optionalTime(notification.ReadAt),
+			notification.CreateAt.Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+func conversationRows(conversations []models.Conversation) [][]string {
+	rows := [][]string{{"id", "member_ids", "created_at", "updated_at"}}
+	for _, conversation := range conversations {
+		var members []string
+		for _, member := range conversation.Members {
+			members = append(members, strconv.FormatUint(member.UserID, 10))
+		}
+		rows = append(rows, []string{
+			strconv.FormatUint(conversation.ID, 10),
+			strings.Join(members, " "),
+			conversation.CreateAt.Format(time.RFC3339),
+			conversation.UpdatedAt.Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+func messageRows(messages []models.Message) [][]string {
+	rows := [][]string{{"id", "conversation_id", "sender_id", "body", "created_at"}}
+	for _, message := range messages {
+		rows = append(rows, []string{
+			strconv.FormatUint(message.ID, 10),
+			strconv.FormatUint(message.ConversationID, 10),
+			strconv.FormatUint(message.SenderID, 10),
+			message.Body,
+			message.CreateAt.Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+// listMember - an account in one of the user's lists, without the
+// account's other details
+type listMember struct {
+	ListID uint64 `json:"list_id"`
+	UserID uint64 `json:"user_id"`
+	Nick   string `json:"nick"`
+}
+
+func listRows(lists []models.List) [][]string {
+	rows := [][]string{{"id", "owner_id", "name", "description", "private", "created_at"}}
+	for _, list := range lists {
+		rows = append(rows, []string{
+			strconv.FormatUint(list.ID, 10),
+			strconv.FormatUint(list.OwnerID, 10),
+			list.Name,
+			list.Description,
+			strconv.FormatBool(list.Private),
+			list.CreateAt.Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+func listMemberRows(members []listMember) [][]string {
+	rows := [][]string{{"list_id", "user_id", "nick"}}
+	for _, member := range members {
+		rows = append(rows, []string{
+			strconv.FormatUint(member.ListID, 10),
+			strconv.FormatUint(member.UserID, 10),
+			member.Nick,
+		})
+	}
+	return rows
+}
+
+func auditRows(entries []models.AuditEntry) [][]string {
+	rows := [][]string{{"id", "action", "target_type", "target_id", "ip", "user_agent", "changes", "created_at"}}
+	for _, entry := range entries {
+		rows = append(rows, []string{
+			strconv.FormatUint(entry.ID, 10),
+			entry.Action,
+			entry.TargetType,
+			entry.TargetID,
+			entry.IP,
+			entry.UserAgent,
+			string(entry.Changes),
+			entry.CreateAt.Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+// optionalTime - RFC 3339 time, empty when unset
+func optionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,157 @@
+package export
+
+import (
+	"api/src/config"
+	"api/src/database"
+	"api/src/models"
+	"api/src/notify"
+	"api/src/repository"
+	"api/src/storage"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// Store - where archives are kept until their link expires, a disk
+	// store under EXPORT_DIR unless replaced before the first export
+	Store     storage.Store
+	storeOnce sync.Once
+)
+
+// Owner - this instance's name on the exports it builds
+var Owner = newOwner()
+
+// LeaseFor - how long an export stays with its owner, well past the job
+// timeout; after that another instance may take it over
+const LeaseFor = 30 * time.Minute
+
+func newOwner() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+func archives() storage.Store {
+	storeOnce.Do(func() {
+		if Store == nil {
+			Store = storage.NewDisk(config.ExportDir)
+		}
+	})
+	return Store
+}
+
+// Job - build the archive of a pending export, store it and notify the
+// user with a download link. Nothing to do when another instance took the
+// export over.
+func Job(exportID string, userID uint64) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		db, err := database.Connect(ctx)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		exportRepo := repository.NewDataExportRepo(db)
+		now := time.Now()
+		claimed, err := exportRepo.Claim(ctx, exportID, Owner, now.Add(LeaseFor), now)
+		if err != nil || !claimed {
+			return err
+		}
+		var archive bytes.Buffer
+		fileKey := fmt.Sprintf("%d/%s.zip", userID, exportID)
+		if err = Build(ctx, db, userID, &archive); err == nil {
+			err = archives().Put(ctx, fileKey, &archive)
+		}
+		if err != nil {
+			if failErr := exportRepo.Fail(ctx, exportID, "could not build the archive"); failErr != nil {
+				log.Printf("export %s: %v", exportID, failErr)
+			}
+			notify.Send(ctx, db, userID, notify.ExportFailed, "Your data export failed, please try again", "")
+			return err
+		}
+
+		expiresAt := time.Now().Add(config.ExportLinkTTL)
+		if err = exportRepo.Complete(ctx, exportID, fileKey, expiresAt); err != nil {
+			archives().Delete(ctx, fileKey)
+			return err
+		}
+		notify.Send(ctx, db, userID, notify.ExportReady,
+			"Your data export is ready to download", Link(exportID, expiresAt))
+		return nil
+	}
+}
+
+// Link - download URL of an export, signed and valid until expiresAt
+func Link(exportID string, expiresAt time.Time) string {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	return fmt.Sprintf("%s/exports/%s/download?expires=%s&signature=%s",
+		config.PublicURL, exportID, expires, signature(exportID, expires))
+}
+
+// Verify - whether a download link's expiry and signature are valid
+func Verify(exportID string, expires string, sig string) bool {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(signature(exportID, expires)))
+}
+
+// WithLink - fill DownloadURL of a ready, unexpired export
+func WithLink(export models.DataExport) models.DataExport {
+	if export.Status == models.ExportReady && export.ExpiresAt != nil && time.Now().Before(*export.ExpiresAt) {
+		export.DownloadURL = Link(export.ID, *export.ExpiresAt)
+	}
+	return export
+}
+
+func signature(exportID string, expires string) string {
+	mac := hmac.New(sha256.New, config.ExportSigningKey)
+	mac.Write([]byte(exportID + "|" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Cleanup - delete archives and records whose link expired, and failed
+// exports once they've been visible for as long as a link would be
+func Cleanup(ctx context.Context) error {
+	db, err := database.Connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	exportRepo := repository.NewDataExportRepo(db)
+	now := time.Now()
+	exports, err := exportRepo.Expired(ctx, now, now.Add(-config.ExportLinkTTL))
+	if err != nil {
+		return err
+	}
+	for _, export := range exports {
+		if export.FileKey != "" {
+			if err = archives().Delete(ctx, export.FileKey); err != nil {
+				return err
+			}
+		}
+		if err = exportRepo.Delete(ctx, export.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Open - read a ready export's archive
+func Open(ctx context.Context, export models.DataExport) (io.ReadCloser, error) {
+	return archives().Open(ctx, export.FileKey)
+}
@@ -1,14 +1,128 @@
 package hash
 
 import (
+	"api/src/config"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// Hash - receive a password as a param and return a password hashed
+var (
+	// ErrMismatch - password does not match the hash
+	ErrMismatch = errors.New("hash: password does not match")
+	// ErrFormat - hash is neither bcrypt nor argon2id PHC
+	ErrFormat = errors.New("hash: unknown hash format")
+)
+
+const argon2SaltLength = 16
+
+// argon2Params - argon2id cost parameters as written in a PHC string
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	keyLength   uint32
+}
+
+// Hash - receive a password as a param and return a password hashed with
+// the algorithm selected by PASSWORD_ALGORITHM
 func Hash(password string) ([]byte, error) {
-	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if config.PasswordAlgorithm == "bcrypt" {
+		return bcrypt.GenerateFromPassword([]byte(password), config.BcryptCost)
+	}
+	return hashArgon2(password, currentArgon2Params())
+}
+
+// Verify - compare password with passwordHash. needsRehash reports that the
+// hash matched but was made with another algorithm or older parameters.
+func Verify(password string, passwordHash string) (needsRehash bool, err error) {
+	if strings.HasPrefix(passwordHash, "$argon2id$") {
+		params, salt, key, err := decodeArgon2(passwordHash)
+		if err != nil {
+			return false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, params.keyLength)
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return false, ErrMismatch
+		}
+		return config.PasswordAlgorithm != "argon2id" || params != currentArgon2Params(), nil
+	}
+
+	if err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, ErrMismatch
+		}
+		return false, err
+	}
+	cost, err := bcrypt.Cost([]byte(passwordHash))
+	if err != nil {
+		return false, err
+	}
+	return config.PasswordAlgorithm != "bcrypt" || cost != config.BcryptCost, nil
+}
+
+func currentArgon2Params() argon2Params {
+	return argon2Params{
+		memory:      config.Argon2Memory,
+		iterations:  config.Argon2Iterations,
+		parallelism: config.Argon2Parallelism,
+		keyLength:   32,
+	}
+}
+
+// hashArgon2 - $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func hashArgon2(password string, params argon2Params) ([]byte, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key := argon2.IDKey([]byte(password), salt, params.iterations, params.memory, params.parallelism, params.keyLength)
+	return encodeArgon2(params, salt, key), nil
+}
+
+func encodeArgon2(params argon2Params, salt []byte, key []byte) []byte {
+	return []byte(fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		params.memory, params.iterations, params.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	))
 }
 
-func Verify(password string, passwordHash string) error {
-	return bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password))
+func decodeArgon2(passwordHash string) (argon2Params, []byte, []byte, error) {
+	parts := strings.Split(passwordHash, "$")
+	if len(parts) != 6 {
+		return argon2Params{}, nil, nil, ErrFormat
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2Params{}, nil, nil, ErrFormat
+	}
+	var params argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return argon2Params{}, nil, nil, ErrFormat
+	}
+	// argon2.IDKey panics on these, a corrupt hash must not take the API down
+	if params.parallelism == 0 || params.iterations == 0 {
+		return argon2Params{}, nil, nil, ErrFormat
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return argon2Params{}, nil, nil, ErrFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return argon2Params{}, nil, nil, ErrFormat
+	}
+	if len(key) == 0 {
+		return argon2Params{}, nil, nil, ErrFormat
+	}
+	params.keyLength = uint32(len(key))
+	return params, salt, key, nil
 }
@@ -0,0 +1,113 @@
+package hash
+
+import (
+	"api/src/config"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// cheap parameters, the defaults make the suite slow
+func useParams(t *testing.T, algorithm string, memory uint32, iterations uint32, parallelism uint8) {
+	t.Helper()
+	a, c, m, i, p := config.PasswordAlgorithm, config.BcryptCost, config.Argon2Memory, config.Argon2Iterations, config.Argon2Parallelism
+	t.Cleanup(func() {
+		config.PasswordAlgorithm, config.BcryptCost = a, c
+		config.Argon2Memory, config.Argon2Iterations, config.Argon2Parallelism = m, i, p
+	})
+	config.PasswordAlgorithm, config.BcryptCost = algorithm, 4
+	config.Argon2Memory, config.Argon2Iterations, config.Argon2Parallelism = memory, iterations, parallelism
+}
+
+func TestHashVerify(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		prefix    string
+	}{
+		{"argon2id", "argon2id", "$argon2id$v=19$m=64,t=1,p=1$"},
+		{"bcrypt", "bcrypt", "$2a$04$"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useParams(t, test.algorithm, 64, 1, 1)
+			hashed, err := Hash("correct horse battery staple")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(hashed), test.prefix) {
+				t.Errorf("hash %q, want prefix %q", hashed, test.prefix)
+			}
+			needsRehash, err := Verify("correct horse battery staple", string(hashed))
+			if err != nil || needsRehash {
+				t.Errorf("Verify = %v, %v; want false, nil", needsRehash, err)
+			}
+			if _, err = Verify("wrong", string(hashed)); !errors.Is(err, ErrMismatch) {
+				t.Errorf("Verify wrong password = %v, want ErrMismatch", err)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	useParams(t, "argon2id", 64, 1, 1)
+	argon, _ := Hash("password")
+	config.PasswordAlgorithm = "bcrypt"
+	bcryptHash, _ := Hash("password")
+	config.PasswordAlgorithm = "argon2id"
+
+	tests := []struct {
+		name   string
+		change func()
+		hash   []byte
+		want   bool
+	}{
+		{"same parameters", func() {}, argon, false},
+		{"more memory", func() { config.Argon2Memory = 128 }, argon, true},
+		{"more iterations", func() { config.Argon2Iterations = 2 }, argon, true},
+		{"more threads", func() { config.Argon2Parallelism = 2 }, argon, true},
+		{"switched to bcrypt", func() { config.PasswordAlgorithm = "bcrypt" }, argon, true},
+		{"bcrypt while argon2id is current", func() {}, bcryptHash, true},
+		{"bcrypt cost raised", func() { config.PasswordAlgorithm, config.BcryptCost = "bcrypt", 5 }, bcryptHash, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useParams(t, "argon2id", 64, 1, 1)
+			test.change()
+			needsRehash, err := Verify("password", string(test.hash))
+			if err != nil || needsRehash != test.want {
+				t.Errorf("Verify = %v, %v; want %v, nil", needsRehash, err, test.want)
+			}
+		})
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"too few parts", "$argon2id$v=19$m=64,t=1,p=1$c2FsdA"},
+		{"other version", "$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5"},
+		{"zero threads", "$argon2id$v=19$m=64,t=1,p=0$c2FsdHNhbHQ$a2V5"},
+		{"zero iterations", "$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHQ$a2V5"},
+		{"bad salt", "$argon2id$v=19$m=64,t=1,p=1$!!!$a2V5"},
+		{"empty key", "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Verify("password", test.hash); !errors.Is(err, ErrFormat) {
+				t.Errorf("Verify = %v, want ErrFormat", err)
+			}
+		})
+	}
+}
+
+// users.password is varchar(255), the largest parameters must still fit
+func TestArgon2HashFitsColumn(t *testing.T) {
+	params := argon2Params{memory: 4294967295, iterations: 4294967295, parallelism: 255, keyLength: 32}
+	encoded := encodeArgon2(params, make([]byte, argon2SaltLength), make([]byte, params.keyLength))
+	if len(encoded) > 255 {
+		t.Errorf("a PHC string with %+v is %d chars, over the column", params, len(encoded))
+	}
+}
@@ -0,0 +1,31 @@
+package jobs
+
+import (
+	"api/src/config"
+	"api/src/database"
+	"api/src/repository"
+	"context"
+	"log"
+	"time"
+)
+
+// PurgeAccounts - every interval, remove accounts deleted longer than
+// ACCOUNT_RESTORE_WINDOW ago
+func PurgeAccounts(interval time.Duration) {
+	for range time.Tick(interval) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		db, err := database.Connect(ctx)
+		if err == nil {
+			var purged int64
+			purged, err = repository.NewUserRepo(db).Purge(ctx, time.Now().Add(-config.AccountRestoreWindow))
+			if purged > 0 {
+				log.Printf("jobs: purged %d deleted accounts", purged)
+			}
+			db.Close()
+		}
+		cancel()
+		if err != nil {
+			log.Printf("jobs: purge accounts: %v", err)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package jobs
+
+import (
+	"api/src/database"
+	"api/src/repository"
+	"context"
+	"log"
+	"time"
+)
+
+// ReconcileCounters - every interval, repair follower/following and list
+// counters that drifted from the rows they count, e.g. after accounts are
+// purged and their follows and list memberships cascade away
+func ReconcileCounters(interval time.Duration) {
+	for range time.Tick(interval) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		db, err := database.Connect(ctx)
+		if err == nil {
+			var users, lists int64
+			users, err = repository.NewUserRepo(db).ReconcileFollowCounts(ctx)
+			if err == nil {
+				lists, err = repository.NewListRepo(db).ReconcileCounts(ctx)
+			}
+			if users > 0 || lists > 0 {
+				log.Printf("jobs: repaired counters of %d users and %d lists", users, lists)
+			}
+			db.Close()
+		}
+		cancel()
+		if err != nil {
+			log.Printf("jobs: reconcile counters: %v", err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package jobs
+
+import (
+	"api/src/database"
+	"api/src/export"
+	"api/src/repository"
+	"context"
+	"log"
+	"time"
+)
+
+// CleanupExports - every interval, delete exports whose link expired and
+// exports that failed
+func CleanupExports(interval time.Duration) {
+	for range time.Tick(interval) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		if err := export.Cleanup(ctx); err != nil {
+			log.Printf("jobs: cleanup exports: %v", err)
+		}
+		cancel()
+	}
+}
+
+// RequeueExports - take over the pending exports whose lease lapsed, their
+// instance stopped before finishing them. Those the queue can't take are
+// failed so their users can ask again.
+func RequeueExports() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	db, err := database.Connect(ctx)
+	if err != nil {
+		log.Printf("jobs: requeue exports: %v", err)
+		return
+	}
+	defer db.Close()
+
+	exportRepo := repository.NewDataExportRepo(db)
+	now := time.Now()
+	lapsed, err := exportRepo.FindLapsed(ctx, now)
+	if err != nil {
+		log.Printf("jobs: requeue exports: %v", err)
+		return
+	}
+	requeued := 0
+	for _, dataExport := range lapsed {
+		// another instance may be taking it over right now
+		claimed, err := exportRepo.Claim(ctx, dataExport.ID, export.Owner, now.Add(export.LeaseFor), now)
+		if err != nil || !claimed {
+			if err != nil {
+				log.Printf("jobs: requeue export %s: %v", dataExport.ID, err)
+			}
+			continue
+		}
+		err = Enqueue("export "+dataExport.ID, export.Job(dataExport.ID, dataExport.UserID))
+		if err == ErrQueueFull {
+			err = exportRepo.Fail(ctx, dataExport.ID, "interrupted by a restart")
+		} else if err == nil {
+			requeued++
+		}
+		if err != nil {
+			log.Printf("jobs: requeue export %s: %v", dataExport.ID, err)
+		}
+	}
+	if requeued > 0 {
+		log.Printf("jobs: requeued %d pending exports", requeued)
+	}
+}
+
+// WatchExports - every interval, requeue the exports of instances that
+// stopped while others kept running
+func WatchExports(interval time.Duration) {
+	for range time.Tick(interval) {
+		RequeueExports()
+	}
+}
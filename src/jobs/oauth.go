@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"api/src/database"
+	"api/src/repository"
+	"context"
+	"log"
+	"time"
+)
+
+// CleanupOAuth - every interval, remove expired authorization codes and
+// access tokens
+func CleanupOAuth(interval time.Duration) {
+	for range time.Tick(interval) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		db, err := database.Connect(ctx)
+		if err == nil {
+			var deleted int64
+			deleted, err = repository.NewOAuthRepo(db).DeleteExpired(ctx, time.Now())
+			if deleted > 0 {
+				log.Printf("jobs: deleted %d expired oauth codes and tokens", deleted)
+			}
+			db.Close()
+		}
+		cancel()
+		if err != nil {
+			log.Printf("jobs: cleanup oauth: %v", err)
+		}
+	}
+}
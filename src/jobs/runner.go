@@ -0,0 +1,82 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// ErrQueueFull - the runner can't take more jobs right now
+var ErrQueueFull = errors.New("jobs: queue is full")
+
+// Job - background work, given a context bounded by the runner
+type Job func(ctx context.Context) error
+
+// Runner - where background jobs are executed
+type Runner interface {
+	// Enqueue - schedule job without waiting, ErrQueueFull when it can't
+	Enqueue(name string, job Job) error
+}
+
+// Local - Runner executing jobs in this process with a pool of workers.
+// Queued jobs are lost when the process stops.
+type Local struct {
+	queue   chan namedJob
+	timeout time.Duration
+}
+
+type namedJob struct {
+	name string
+	job  Job
+}
+
+// Default - runner used by Enqueue, set by Start
+var Default Runner
+
+// Start - set Default to a local runner with workers goroutines
+func Start(workers int, timeout time.Duration) {
+	Default = NewLocal(workers, 100, timeout)
+}
+
+// NewLocal - start workers consuming an in-memory queue of size jobs
+func NewLocal(workers int, size int, timeout time.Duration) *Local {
+	runner := &Local{queue: make(chan namedJob, size), timeout: timeout}
+	for i := 0; i < workers; i++ {
+		go runner.work()
+	}
+	return runner
+}
+
+func (l *Local) Enqueue(name string, job Job) error {
+	select {
+	case l.queue <- namedJob{name, job}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (l *Local) work() {
+	for queued := range l.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+		if err := queued.job(ctx); err != nil {
+			log.Printf("jobs: %s: %v", queued.name, err)
+		}
+		cancel()
+	}
+}
+
+// Enqueue - run job on the Default runner, or in its own goroutine when
+// no runner was started
+func Enqueue(name string, job Job) error {
+	if Default != nil {
+		return Default.Enqueue(name, job)
+	}
+	go func() {
+		if err := job(context.Background()); err != nil {
+			log.Printf("jobs: %s: %v", name, err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,101 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLocalRunsJobs(t *testing.T) {
+	runner := NewLocal(2, 10, time.Second)
+	var ran int32
+	done := make(chan struct{}, 5)
+	for i := 0; i < 5; i++ {
+		err := runner.Enqueue("count", func(ctx context.Context) error {
+			atomic.AddInt32(&ran, 1)
+			done <- struct{}{}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("%d of 5 jobs ran", atomic.LoadInt32(&ran))
+		}
+	}
+}
+
+func TestLocalBoundsJobsWithTimeout(t *testing.T) {
+	runner := NewLocal(1, 1, 10*time.Millisecond)
+	result := make(chan error, 1)
+	runner.Enqueue("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		result <- ctx.Err()
+		return ctx.Err()
+	})
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("job context ended with %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("job context never timed out")
+	}
+}
+
+func TestLocalEnqueueDoesNotBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		enqueue int
+		wantErr int
+	}{
+		{"room left", 3, 3, 0},
+		{"full", 2, 5, 3},
+		{"no queue", 0, 1, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// no workers, nothing drains the queue
+			runner := NewLocal(0, test.size, time.Second)
+			errs := 0
+			for i := 0; i < test.enqueue; i++ {
+				if err := runner.Enqueue("noop", func(context.Context) error { return nil }); err != nil {
+					if err != ErrQueueFull {
+						t.Fatalf("Enqueue: %v", err)
+					}
+					errs++
+				}
+			}
+			if errs != test.wantErr {
+				t.Errorf("%d enqueues refused, want %d", errs, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnqueueUsesDefault(t *testing.T) {
+	defer func(runner Runner) { Default = runner }(Default)
+
+	Default = nil
+	done := make(chan struct{})
+	if err := Enqueue("fallback", func(context.Context) error { close(done); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("job without a runner never ran")
+	}
+
+	Default = NewLocal(0, 0, time.Second)
+	if err := Enqueue("full", func(context.Context) error { return nil }); err != ErrQueueFull {
+		t.Errorf("Enqueue on a full runner = %v, want ErrQueueFull", err)
+	}
+}
@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"api/src/database"
+	"api/src/suggestions"
+	"context"
+	"log"
+	"time"
+)
+
+// RefreshSuggestions - every interval, drop the cached follow suggestions
+// of inactive users, recompute those of active users that expired and fill
+// them for active users without any
+func RefreshSuggestions(interval time.Duration) {
+	for range time.Tick(interval) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		db, err := database.Connect(ctx)
+		if err == nil {
+			var pruned int64
+			if pruned, err = suggestions.Prune(ctx, db); pruned > 0 {
+				log.Printf("jobs: dropped %d cached suggestion rows of inactive users", pruned)
+			}
+			var users []uint64
+			if err == nil {
+				users, err = suggestions.Due(ctx, db, 500)
+			}
+			for _, userID := range users {
+				if _, err = suggestions.Refresh(ctx, db, userID); err != nil {
+					break
+				}
+			}
+			db.Close()
+		}
+		cancel()
+		if err != nil {
+			log.Printf("jobs: refresh suggestions: %v", err)
+		}
+	}
+}
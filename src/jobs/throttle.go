@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"api/src/config"
+	"api/src/database"
+	"api/src/ratelimit"
+	"api/src/repository"
+	"context"
+	"log"
+	"time"
+)
+
+// CleanupLoginAttempts - every interval, remove failed login attempts that
+// no longer count, one row is left behind for every email and IP tried
+func CleanupLoginAttempts(interval time.Duration) {
+	for range time.Tick(interval) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		db, err := database.Connect(ctx)
+		if err == nil {
+			var deleted int64
+			deleted, err = repository.NewLoginAttemptRepo(db).DeleteExpired(ctx, time.Now(), config.LoginLockout)
+			if deleted > 0 {
+				log.Printf("jobs: deleted %d expired login attempts", deleted)
+			}
+			db.Close()
+		}
+		cancel()
+		if err != nil {
+			log.Printf("jobs: cleanup login attempts: %v", err)
+		}
+	}
+}
+
+// CleanupRateLimits - every interval, remove the counters of windows no
+// rule looks at anymore: Allow reads the current and the previous one
+func CleanupRateLimits(interval time.Duration) {
+	for range time.Tick(interval) {
+		window := ratelimit.LongestWindow()
+		if window == 0 {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		db, err := database.Connect(ctx)
+		if err == nil {
+			var deleted int64
+			deleted, err = repository.NewRateLimitRepo(db).DeleteExpired(ctx, time.Now().Add(-2*window))
+			if deleted > 0 {
+				log.Printf("jobs: deleted %d expired rate limit counters", deleted)
+			}
+			db.Close()
+		}
+		cancel()
+		if err != nil {
+			log.Printf("jobs: cleanup rate limits: %v", err)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package middlewares
 import (
 	"api/src/authentication"
 	"api/src/utils"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 )
@@ -16,10 +18,42 @@ func Logger(next http.HandlerFunc) http.HandlerFunc {
 
 func Authentication(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := authentication.VerifyToken(r); err != nil {
+		r, err := authentication.Authenticate(r)
+		if err != nil {
 			utils.Error(w, http.StatusUnauthorized, err)
 			return
 		}
 		next(w, r)
 	}
 }
+
+func Admin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := authentication.GetUserID(r)
+		if err != nil {
+			utils.Error(w, http.StatusUnauthorized, err)
+			return
+		}
+		if !authentication.IsAdmin(userID) {
+			utils.Error(w, http.StatusForbidden, errors.New("User unauthorized"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Scope - refuse access tokens that were not granted scope, and every
+// access token when the route names no scope
+func Scope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authentication.HasScope(r, scope) {
+			if scope == "" {
+				utils.Error(w, http.StatusForbidden, errors.New("Route not available to access tokens"))
+				return
+			}
+			utils.Error(w, http.StatusForbidden, fmt.Errorf("Token lacks the %s scope", scope))
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,95 @@
+package middlewares
+
+import (
+	"api/src/config"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORS - add the CORS headers for allowed origins to a route's responses
+func CORS(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// responses differ per Origin, caches must not share them
+		w.Header().Add("Vary", "Origin")
+		if allowed := allowedOrigin(r.Header.Get("Origin")); allowed != "" {
+			setOrigin(w, allowed)
+		}
+		next(w, r)
+	}
+}
+
+// Preflight - answer OPTIONS for a URI served with the given methods
+func Preflight(methods []string) http.HandlerFunc {
+	allowMethods := strings.Join(methods, ", ")
+	if len(config.CorsAllowedMethods) > 0 {
+		allowMethods = strings.Join(config.CorsAllowedMethods, ", ")
+	}
+	allowHeaders := strings.Join(config.CorsAllowedHeaders, ", ")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		allowed := allowedOrigin(r.Header.Get("Origin"))
+		if allowed == "" {
+			w.Header().Set("Allow", allowMethods)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		setOrigin(w, allowed)
+		w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+		if allowHeaders != "" {
+			w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+		} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			w.Header().Set("Access-Control-Allow-Headers", requested)
+		}
+		if config.CorsMaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(config.CorsMaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// setOrigin - allow origin; a literal "*" never comes with credentials,
+// config refuses to start with both
+func setOrigin(w http.ResponseWriter, origin string) {
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if config.CorsAllowCredentials && origin != "*" {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// allowedOrigin - match origin against CORS_ALLOWED_ORIGINS and return the
+// Access-Control-Allow-Origin value, empty when it isn't allowed. Listed
+// origins and "https://*.example.com" subdomains are echoed back, "*"
+// answers a literal "*".
+func allowedOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	wildcard := false
+	for _, allowed := range config.CorsAllowedOrigins {
+		if allowed == "*" {
+			wildcard = true
+			continue
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+		star := strings.Index(allowed, "*.")
+		if star < 0 {
+			continue
+		}
+		prefix, suffix := allowed[:star], allowed[star+1:]
+		host := strings.TrimPrefix(strings.ToLower(origin), strings.ToLower(prefix))
+		if len(host) != len(origin)-len(prefix) || !strings.HasSuffix(host, strings.ToLower(suffix)) {
+			continue
+		}
+		if subdomain := strings.TrimSuffix(host, strings.ToLower(suffix)); subdomain != "" && !strings.ContainsAny(subdomain, "/:") {
+			return origin
+		}
+	}
+	if wildcard {
+		return "*"
+	}
+	return ""
+}
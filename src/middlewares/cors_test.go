@@ -0,0 +1,84 @@
+package middlewares
+
+import (
+	"api/src/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowedOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    string
+	}{
+		{"no origin", []string{"*"}, "", ""},
+		{"nothing configured", nil, "https://app.example.com", ""},
+		{"exact", []string{"https://app.example.com"}, "https://app.example.com", "https://app.example.com"},
+		{"exact ignores case", []string{"https://App.Example.com"}, "https://app.example.com", "https://app.example.com"},
+		{"other origin", []string{"https://app.example.com"}, "https://evil.com", ""},
+		{"subdomain", []string{"https://*.example.com"}, "https://a.example.com", "https://a.example.com"},
+		{"nested subdomain", []string{"https://*.example.com"}, "https://a.b.example.com", "https://a.b.example.com"},
+		{"bare domain is no subdomain", []string{"https://*.example.com"}, "https://example.com", ""},
+		{"suffix trick", []string{"https://*.example.com"}, "https://evilexample.com", ""},
+		{"other scheme", []string{"https://*.example.com"}, "http://a.example.com", ""},
+		{"port smuggled in subdomain", []string{"https://*.example.com"}, "https://a:1@b.example.com", ""},
+		{"wildcard is literal", []string{"*"}, "https://evil.com", "*"},
+		{"listed wins over wildcard", []string{"*", "https://app.example.com"}, "https://app.example.com", "https://app.example.com"},
+	}
+	defer func(origins []string) { config.CorsAllowedOrigins = origins }(config.CorsAllowedOrigins)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config.CorsAllowedOrigins = test.allowed
+			if got := allowedOrigin(test.origin); got != test.want {
+				t.Errorf("allowedOrigin(%q) = %q, want %q", test.origin, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCORSHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		allowed     []string
+		credentials bool
+		origin      string
+		wantOrigin  string
+		wantCreds   string
+	}{
+		{"listed with credentials", []string{"https://app.example.com"}, true, "https://app.example.com", "https://app.example.com", "true"},
+		{"wildcard never sends credentials", []string{"*"}, true, "https://evil.com", "*", ""},
+		{"not allowed", []string{"https://app.example.com"}, true, "https://evil.com", "", ""},
+		{"no credentials", []string{"https://app.example.com"}, false, "https://app.example.com", "https://app.example.com", ""},
+	}
+	defer func(origins []string, credentials bool) {
+		config.CorsAllowedOrigins, config.CorsAllowCredentials = origins, credentials
+	}(config.CorsAllowedOrigins, config.CorsAllowCredentials)
+	handlers := map[string]http.HandlerFunc{
+		"route":     CORS(func(w http.ResponseWriter, r *http.Request) {}),
+		"preflight": Preflight([]string{http.MethodGet}),
+	}
+	for _, test := range tests {
+		for kind, handler := range handlers {
+			t.Run(test.name+"/"+kind, func(t *testing.T) {
+				config.CorsAllowedOrigins, config.CorsAllowCredentials = test.allowed, test.credentials
+				r := httptest.NewRequest(http.MethodOptions, "/users", nil)
+				r.Header.Set("Origin", test.origin)
+				w := httptest.NewRecorder()
+				handler(w, r)
+
+				if got := w.Header().Get("Access-Control-Allow-Origin"); got != test.wantOrigin {
+					t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, test.wantOrigin)
+				}
+				if got := w.Header().Get("Access-Control-Allow-Credentials"); got != test.wantCreds {
+					t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, test.wantCreds)
+				}
+				if got := w.Header().Get("Vary"); got != "Origin" {
+					t.Errorf("Vary = %q, want Origin", got)
+				}
+			})
+		}
+	}
+}
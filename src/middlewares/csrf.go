@@ -0,0 +1,24 @@
+package middlewares
+
+import (
+	"api/src/authentication"
+	"api/src/utils"
+	"errors"
+	"net/http"
+)
+
+// CSRF - state-changing requests authenticated by cookie must carry the
+// CSRF token. Header authenticated requests can't be forged cross-site.
+func CSRF(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if authentication.FromCookie(r) && !authentication.VerifyCSRF(r) {
+				utils.Error(w, http.StatusForbidden, errors.New("Missing or invalid CSRF token"))
+				return
+			}
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"api/src/authentication"
+	"api/src/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	defer func(enabled bool) { config.AuthCookie = enabled }(config.AuthCookie)
+	config.AuthCookie = true
+
+	tests := []struct {
+		name   string
+		method string
+		header string
+		cookie bool
+		csrf   string
+		want   int
+	}{
+		{"read by cookie", http.MethodGet, "", true, "", http.StatusOK},
+		{"write by cookie without token", http.MethodPost, "", true, "", http.StatusForbidden},
+		{"write by cookie with other token", http.MethodPost, "", true, "other", http.StatusForbidden},
+		{"write by cookie with token", http.MethodPost, "", true, "csrf-value", http.StatusOK},
+		{"write by header", http.MethodDelete, "Bearer token", true, "", http.StatusOK},
+		{"write without credentials", http.MethodPost, "", false, "", http.StatusOK},
+	}
+	handler := CSRF(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	for _, test := range tests {
+		request := httptest.NewRequest(test.method, "/", nil)
+		if test.header != "" {
+			request.Header.Set("Authorization", test.header)
+		}
+		if test.cookie {
+			request.AddCookie(&http.Cookie{Name: config.AuthCookieName, Value: "token"})
+			request.AddCookie(&http.Cookie{Name: config.CSRFCookieName, Value: "csrf-value"})
+		}
+		if test.csrf != "" {
+			request.Header.Set(authentication.CSRFHeader, test.csrf)
+		}
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+		if recorder.Code != test.want {
+			t.Errorf("%s: status %d, want %d", test.name, recorder.Code, test.want)
+		}
+	}
+}
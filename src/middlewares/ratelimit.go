@@ -0,0 +1,73 @@
+package middlewares
+
+import (
+	"api/src/authentication"
+	"api/src/config"
+	"api/src/database"
+	"api/src/ratelimit"
+	"api/src/repository"
+	"api/src/utils"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// rateLimits - in-memory counters used unless RATE_LIMIT_STORE=sql
+var rateLimits = ratelimit.NewMemoryStore()
+
+// RateLimit - limit requests to a route per user, or per IP for anonymous
+// callers. scope separates the counters of different routes.
+func RateLimit(scope string, rule ratelimit.Rule, next http.HandlerFunc) http.HandlerFunc {
+	if rule == (ratelimit.Rule{}) {
+		rule = ratelimit.Rule{Requests: config.RateLimitRequests, Window: config.RateLimitWindow}
+	}
+	if rule.Window == 0 {
+		rule.Window = config.RateLimitWindow
+	}
+	if rule.Requests < 0 {
+		return next
+	}
+	if err := rule.Validate(); err != nil {
+		log.Fatalf("rate limit for %s: %v", scope, err)
+	}
+	ratelimit.Track(rule)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// runs before Authentication, so the caller is taken from the token
+		// itself rather than verified against the database
+		key := scope + "|ip:" + utils.ClientIP(r)
+		if caller := authentication.RateLimitKey(r); caller != "" {
+			key = scope + "|" + caller
+		}
+
+		var store ratelimit.Store = rateLimits
+		if config.RateLimitStore == "sql" {
+			db, err := database.Pool()
+			if err != nil {
+				utils.Error(w, http.StatusInternalServerError, err)
+				return
+			}
+			store = repository.NewRateLimitRepo(db)
+		}
+
+		result, err := ratelimit.Allow(r.Context(), store, key, rule, time.Now())
+		if err != nil {
+			utils.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		reset := fmt.Sprintf("%.0f", math.Ceil(result.Reset.Seconds()))
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", reset)
+		if !result.Allowed {
+			w.Header().Set("Retry-After", reset)
+			utils.Error(w, http.StatusTooManyRequests, errors.New("Rate limit exceeded"))
+			return
+		}
+		next(w, r)
+	}
+}
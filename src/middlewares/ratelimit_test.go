@@ -0,0 +1,65 @@
+package middlewares
+
+import (
+	"api/src/authentication"
+	"api/src/config"
+	"api/src/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitKeys(t *testing.T) {
+	defer func(secret []byte) { config.SecretKey = secret }(config.SecretKey)
+	config.SecretKey = []byte("test secret")
+	alice, err := authentication.Token(1, "session-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceAgain, err := authentication.Token(1, "session-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := authentication.Token(2, "session-c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		first  string
+		second string
+		// whether the second request shares the first's single request
+		wantLimited bool
+	}{
+		{"same user on two sessions", alice, aliceAgain, true},
+		{"two users", alice, bob, false},
+		{"two unverified access tokens", "pat_one", "pat_two", true},
+		{"unverified access token and anonymous", "oat_one", "", true},
+		{"anonymous from one ip", "", "", true},
+		{"forged jwt counts as anonymous", "", alice[:len(alice)-4] + "AAAA", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rateLimits = ratelimit.NewMemoryStore()
+			handler := RateLimit("test", ratelimit.Rule{Requests: 1, Window: time.Hour}, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			status := 0
+			for _, token := range []string{test.first, test.second} {
+				request := httptest.NewRequest(http.MethodGet, "/", nil)
+				request.RemoteAddr = "192.0.2.1:1234"
+				if token != "" {
+					request.Header.Set("Authorization", "Bearer "+token)
+				}
+				recorder := httptest.NewRecorder()
+				handler(recorder, request)
+				status = recorder.Code
+			}
+			if limited := status == http.StatusTooManyRequests; limited != test.wantLimited {
+				t.Errorf("second request status %d, want limited %v", status, test.wantLimited)
+			}
+		})
+	}
+}
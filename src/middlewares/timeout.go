@@ -0,0 +1,22 @@
+package middlewares
+
+import (
+	"api/src/config"
+	"context"
+	"net/http"
+	"time"
+)
+
+// Timeout - bound the request's context so database work is abandoned once
+// the deadline passes. A zero timeout falls back to config.QueryTimeout.
+func Timeout(timeout time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deadline := timeout
+		if deadline == 0 {
+			deadline = config.QueryTimeout
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), deadline)
+		defer cancel()
+		next(w, r.WithContext(ctx))
+	}
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scopes a personal access token can be granted
+var AccessTokenScopes = []string{
+	"read:users",
+	"read:follows",
+	"write:follows",
+	"read:messages",
+	"write:messages",
+	"read:lists",
+	"write:lists",
+}
+
+// AccessToken - personal access token for scripts and integrations. Only
+// its hash is stored, Token is filled once, when it is created.
+type AccessToken struct {
+	ID         uint64     `json:"id,omitempty"`
+	UserID     uint64     `json:"user_id,omitempty"`
+	Name       string     `json:"name,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	Token      string     `json:"token,omitempty"`
+	Hash       string     `json:"-"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreateAt   time.Time  `json:"CreateAt,omitempty"`
+}
+
+func (token *AccessToken) Prepare() error {
+	token.Name = strings.TrimSpace(token.Name)
+	if token.Name == "" {
+		return errors.New("Name: invalid arguments")
+	}
+	if len(token.Scopes) == 0 {
+		return errors.New("Scopes: invalid arguments")
+	}
+	for _, scope := range token.Scopes {
+		if !knownScope(scope) {
+			return fmt.Errorf("Scopes: unknown scope %q", scope)
+		}
+	}
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now()) {
+		return errors.New("ExpiresAt: must be in the future")
+	}
+	return nil
+}
+
+// Expired - whether the token can no longer be used
+func (token AccessToken) Expired(now time.Time) bool {
+	return token.ExpiresAt != nil && !now.Before(*token.ExpiresAt)
+}
+
+func knownScope(scope string) bool {
+	for _, known := range AccessTokenScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
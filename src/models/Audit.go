@@ -0,0 +1,37 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry - one security relevant or administrative action
+type AuditEntry struct {
+	ID         uint64          `json:"id"`
+	ActorID    uint64          `json:"actor_id,omitempty"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type,omitempty"`
+	TargetID   string          `json:"target_id,omitempty"`
+	IP         string          `json:"ip"`
+	UserAgent  string          `json:"user_agent"`
+	Changes    json.RawMessage `json:"changes,omitempty"`
+	CreateAt   time.Time       `json:"CreateAt"`
+}
+
+// AuditFilter - criteria to query the audit log, zero values match all
+type AuditFilter struct {
+	ActorID    uint64
+	Action     string
+	TargetType string
+	TargetID   string
+	From       time.Time
+	To         time.Time
+	Page       int
+	Limit      int
+}
+
+// Change - a field's value before and after an update
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Status of a data export
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// DataExport - archive of everything held about an user, built in the
+// background
+type DataExport struct {
+	ID          string     `json:"id"`
+	UserID      uint64     `json:"user_id"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	FileKey     string     `json:"-"`
+	DownloadURL string     `json:"download_url,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CreateAt    time.Time  `json:"CreateAt"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Identity - an account at an external identity provider linked to an user
+type Identity struct {
+	Issuer   string    `json:"issuer"`
+	Subject  string    `json:"subject"`
+	UserID   uint64    `json:"user_id,omitempty"`
+	CreateAt time.Time `json:"CreateAt"`
+}
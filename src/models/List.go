@@ -0,0 +1,38 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// List - a named, curated set of accounts. MemberCount only counts active
+// accounts, like the members listing.
+type List struct {
+	ID              uint64    `json:"id,omitempty"`
+	OwnerID         uint64    `json:"owner_id,omitempty"`
+	Name            string    `json:"name,omitempty"`
+	Description     string    `json:"description"`
+	Private         bool      `json:"private"`
+	MemberCount     int       `json:"member_count"`
+	SubscriberCount int       `json:"subscriber_count"`
+	CreateAt        time.Time `json:"CreateAt,omitempty"`
+}
+
+func (list *List) Prepare() error {
+	list.Name = strings.TrimSpace(list.Name)
+	list.Description = strings.TrimSpace(list.Description)
+	if list.Name == "" || len(list.Name) > 55 {
+		return errors.New("Name: invalid arguments")
+	}
+	if len(list.Description) > 255 {
+		return errors.New("Description: invalid arguments")
+	}
+	return nil
+}
+
+// VisibleTo - whether an user may see the list, private lists are only
+// visible to their owner
+func (list List) VisibleTo(userID uint64) bool {
+	return list.ID != 0 && (!list.Private || list.OwnerID == userID)
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestListPrepare(t *testing.T) {
+	tests := []struct {
+		name            string
+		list            List
+		wantName        string
+		wantDescription string
+		wantErr         bool
+	}{
+		{"valid", List{Name: "friends", Description: "people I know"}, "friends", "people I know", false},
+		{"trimmed", List{Name: "  friends\n", Description: " people "}, "friends", "people", false},
+		{"no description", List{Name: "friends"}, "friends", "", false},
+		{"blank name", List{Name: "   "}, "", "", true},
+		{"name too long", List{Name: strings.Repeat("a", 56)}, "", "", true},
+		{"name at the limit", List{Name: strings.Repeat("a", 55)}, strings.Repeat("a", 55), "", false},
+		{"description too long", List{Name: "friends", Description: strings.Repeat("a", 256)}, "", "", true},
+	}
+	for _, test := range tests {
+		err := test.list.Prepare()
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: Prepare = %v, want error %v", test.name, err, test.wantErr)
+			continue
+		}
+		if err == nil && (test.list.Name != test.wantName || test.list.Description != test.wantDescription) {
+			t.Errorf("%s: %q, %q, want %q, %q", test.name, test.list.Name, test.list.Description, test.wantName, test.wantDescription)
+		}
+	}
+}
+
+func TestListVisibleTo(t *testing.T) {
+	tests := []struct {
+		name   string
+		list   List
+		userID uint64
+		want   bool
+	}{
+		{"public, owner", List{ID: 1, OwnerID: 1}, 1, true},
+		{"public, someone else", List{ID: 1, OwnerID: 1}, 2, true},
+		{"private, owner", List{ID: 1, OwnerID: 1, Private: true}, 1, true},
+		{"private, someone else", List{ID: 1, OwnerID: 1, Private: true}, 2, false},
+		{"not found", List{}, 0, false},
+	}
+	for _, test := range tests {
+		if got := test.list.VisibleTo(test.userID); got != test.want {
+			t.Errorf("%s: VisibleTo = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// LoginAttempt - failed login bookkeeping for an email or a client IP
+type LoginAttempt struct {
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure,omitempty"`
+	LockedUntil time.Time `json:"locked_until,omitempty"`
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"api/src/config"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Conversation - a one-to-one or small group thread of direct messages
+type Conversation struct {
+	ID          uint64               `json:"id"`
+	Members     []ConversationMember `json:"members"`
+	LastMessage *Message             `json:"last_message,omitempty"`
+	Unread      int                  `json:"unread"`
+	CreateAt    time.Time            `json:"CreateAt"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+// ConversationMember - a participant, LastReadID is the read receipt
+type ConversationMember struct {
+	UserID     uint64 `json:"user_id"`
+	Nick       string `json:"nick"`
+	LastReadID uint64 `json:"last_read_id"`
+}
+
+// Message - a direct message
+type Message struct {
+	ID             uint64    `json:"id"`
+	ConversationID uint64    `json:"conversation_id"`
+	SenderID       uint64    `json:"sender_id"`
+	Body           string    `json:"body"`
+	CreateAt       time.Time `json:"CreateAt"`
+}
+
+// NewConversation - request body to start a conversation
+type NewConversation struct {
+	MemberIDs []uint64 `json:"member_ids"`
+	Body      string   `json:"body,omitempty"`
+}
+
+// MessagingSettings - who may message an user
+type MessagingSettings struct {
+	// FollowingOnly - only accept messages from people the user follows
+	FollowingOnly bool `json:"following_only"`
+}
+
+// Prepare - dedupe members, leaving out the creator
+func (conversation *NewConversation) Prepare(creatorID uint64) error {
+	seen := map[uint64]bool{creatorID: true}
+	var members []uint64
+	for _, id := range conversation.MemberIDs {
+		if id == 0 {
+			return errors.New("MemberIDs: invalid arguments")
+		}
+		if !seen[id] {
+			seen[id] = true
+			members = append(members, id)
+		}
+	}
+	if len(members) == 0 {
+		return errors.New("MemberIDs: invalid arguments")
+	}
+	if len(members)+1 > config.MessageMaxMembers {
+		return fmt.Errorf("MemberIDs: at most %d members", config.MessageMaxMembers)
+	}
+	conversation.MemberIDs = members
+	conversation.Body = strings.TrimSpace(conversation.Body)
+	return nil
+}
+
+func (message *Message) Prepare() error {
+	message.Body = strings.TrimSpace(message.Body)
+	if message.Body == "" {
+		return errors.New("Body: invalid arguments")
+	}
+	if utf8.RuneCountInString(message.Body) > config.MessageMaxLength {
+		return fmt.Errorf("Body: at most %d characters", config.MessageMaxLength)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"api/src/config"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewConversationPrepare(t *testing.T) {
+	previous := config.MessageMaxMembers
+	t.Cleanup(func() { config.MessageMaxMembers = previous })
+	config.MessageMaxMembers = 3
+
+	tests := []struct {
+		name        string
+		memberIDs   []uint64
+		wantMembers []uint64
+		wantErr     bool
+	}{
+		{"one to one", []uint64{2}, []uint64{2}, false},
+		{"duplicates", []uint64{2, 3, 2}, []uint64{2, 3}, false},
+		{"creator left out", []uint64{1, 2}, []uint64{2}, false},
+		{"only the creator", []uint64{1}, nil, true},
+		{"no members", nil, nil, true},
+		{"zero ID", []uint64{2, 0}, nil, true},
+		{"at the limit", []uint64{2, 3}, []uint64{2, 3}, false},
+		{"over the limit", []uint64{2, 3, 4}, nil, true},
+	}
+	for _, test := range tests {
+		conversation := NewConversation{MemberIDs: test.memberIDs, Body: " hi "}
+		err := conversation.Prepare(1)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: Prepare = %v, want error %v", test.name, err, test.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(conversation.MemberIDs, test.wantMembers) {
+			t.Errorf("%s: members %v, want %v", test.name, conversation.MemberIDs, test.wantMembers)
+		}
+		if err == nil && conversation.Body != "hi" {
+			t.Errorf("%s: body %q, want it trimmed", test.name, conversation.Body)
+		}
+	}
+}
+
+func TestMessagePrepare(t *testing.T) {
+	previous := config.MessageMaxLength
+	t.Cleanup(func() { config.MessageMaxLength = previous })
+	config.MessageMaxLength = 5
+
+	tests := []struct {
+		body     string
+		wantBody string
+		wantErr  bool
+	}{
+		{"hello", "hello", false},
+		{"  hello\n", "hello", false},
+		{"héllo", "héllo", false},
+		{"hello!", "", true},
+		{"   ", "", true},
+		{"", "", true},
+	}
+	for _, test := range tests {
+		message := Message{Body: test.body}
+		err := message.Prepare()
+		if (err != nil) != test.wantErr {
+			t.Errorf("Prepare(%q) = %v, want error %v", test.body, err, test.wantErr)
+			continue
+		}
+		if err == nil && message.Body != test.wantBody {
+			t.Errorf("Prepare(%q) body %q, want %q", test.body, message.Body, test.wantBody)
+		}
+	}
+	if message := (Message{Body: strings.Repeat("é", 5)}); message.Prepare() != nil {
+		t.Error("Prepare counts bytes, want characters")
+	}
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Notification - something an user should know about
+type Notification struct {
+	ID       uint64     `json:"id"`
+	UserID   uint64     `json:"user_id,omitempty"`
+	Kind     string     `json:"kind"`
+	Message  string     `json:"message"`
+	Link     string     `json:"link,omitempty"`
+	ReadAt   *time.Time `json:"read_at,omitempty"`
+	CreateAt time.Time  `json:"CreateAt"`
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"api/src/config"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OAuthClient - third-party application registered by an user
+type OAuthClient struct {
+	ID           string    `json:"client_id"`
+	Secret       string    `json:"client_secret,omitempty"`
+	SecretHash   string    `json:"-"`
+	OwnerID      uint64    `json:"owner_id,omitempty"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	CreateAt     time.Time `json:"CreateAt,omitempty"`
+}
+
+func (client *OAuthClient) Prepare() error {
+	client.Name = strings.TrimSpace(client.Name)
+	if client.Name == "" {
+		return errors.New("Name: invalid arguments")
+	}
+	if len(client.RedirectURIs) == 0 {
+		return errors.New("RedirectURIs: invalid arguments")
+	}
+	for _, redirect := range client.RedirectURIs {
+		if !redirectAllowed(redirect) {
+			return fmt.Errorf("RedirectURIs: invalid redirect uri %q", redirect)
+		}
+	}
+	if len(client.Scopes) == 0 {
+		return errors.New("Scopes: invalid arguments")
+	}
+	for _, scope := range client.Scopes {
+		if !knownScope(scope) {
+			return fmt.Errorf("Scopes: unknown scope %q", scope)
+		}
+	}
+	return nil
+}
+
+// redirectAllowed - https, http to the loopback interface for native apps,
+// or a custom scheme listed in OAUTH_REDIRECT_SCHEMES. Anything else, like
+// javascript: or data:, would run in the frontend that follows it.
+func redirectAllowed(redirect string) bool {
+	uri, err := url.Parse(redirect)
+	if err != nil || !uri.IsAbs() || uri.Fragment != "" {
+		return false
+	}
+	switch scheme := strings.ToLower(uri.Scheme); scheme {
+	case "https":
+		return uri.Host != ""
+	case "http":
+		switch uri.Hostname() {
+		case "localhost", "127.0.0.1", "::1":
+			return true
+		}
+		return false
+	default:
+		for _, registered := range config.OAuthRedirectSchemes {
+			if strings.EqualFold(registered, scheme) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AllowsRedirect - redirect uris must match a registered one exactly
+func (client OAuthClient) AllowsRedirect(redirect string) bool {
+	for _, registered := range client.RedirectURIs {
+		if registered == redirect {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsScopes - whether every requested scope was registered
+func (client OAuthClient) AllowsScopes(scopes []string) bool {
+	for _, scope := range scopes {
+		allowed := false
+		for _, registered := range client.Scopes {
+			allowed = allowed || registered == scope
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// OAuthCode - authorization code waiting to be exchanged for a token
+type OAuthCode struct {
+	Hash          string
+	ClientID      string
+	UserID        uint64
+	RedirectURI   string
+	Scopes        []string
+	CodeChallenge string
+	ExpiresAt     time.Time
+}
+
+// OAuthToken - access token issued to a client, for an user or, with the
+// client credentials grant, for the client itself (UserID zero)
+type OAuthToken struct {
+	Hash      string
+	ClientID  string
+	UserID    uint64
+	Scopes    []string
+	ExpiresAt time.Time
+	Revoked   bool
+}
+
+// OAuthGrant - access an user gave a client, as listed in data exports
+type OAuthGrant struct {
+	ClientID   string     `json:"client_id"`
+	ClientName string     `json:"client_name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreateAt   time.Time  `json:"CreateAt"`
+}
+
+// Active - whether the token can still be used
+func (token OAuthToken) Active(now time.Time) bool {
+	return token.Hash != "" && !token.Revoked && now.Before(token.ExpiresAt)
+}
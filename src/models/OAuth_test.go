@@ -0,0 +1,79 @@
+package models
+
+import (
+	"api/src/config"
+	"testing"
+	"time"
+)
+
+func TestOAuthClientRedirectURIs(t *testing.T) {
+	previous := config.OAuthRedirectSchemes
+	t.Cleanup(func() { config.OAuthRedirectSchemes = previous })
+	config.OAuthRedirectSchemes = []string{"com.example.app"}
+
+	tests := []struct {
+		redirect string
+		wantErr  bool
+	}{
+		{"https://app.example/callback", false},
+		{"http://localhost:8080/callback", false},
+		{"http://127.0.0.1:51000/callback", false},
+		{"http://[::1]:51000/callback", false},
+		{"com.example.app:/oauth", false},
+		{"COM.EXAMPLE.APP:/oauth", false},
+		{"http://app.example/callback", true},
+		{"http://localhost.evil.example/callback", true},
+		{"https:///callback", true},
+		{"https://app.example/callback#fragment", true},
+		{"/callback", true},
+		{"javascript:alert(document.cookie)", true},
+		{"data:text/html,<script>alert(1)</script>", true},
+		{"com.other.app:/oauth", true},
+	}
+	for _, test := range tests {
+		client := OAuthClient{Name: "app", RedirectURIs: []string{test.redirect}, Scopes: []string{"read:users"}}
+		if err := client.Prepare(); (err != nil) != test.wantErr {
+			t.Errorf("Prepare(%q) = %v, want error %v", test.redirect, err, test.wantErr)
+		}
+	}
+}
+
+func TestOAuthClientAllowsScopes(t *testing.T) {
+	client := OAuthClient{Scopes: []string{"read:users", "write:follows"}}
+	tests := []struct {
+		scopes []string
+		want   bool
+	}{
+		{nil, true},
+		{[]string{"read:users"}, true},
+		{[]string{"write:follows", "read:users"}, true},
+		{[]string{"read:users", "write:users"}, false},
+		{[]string{"manage:tokens"}, false},
+		{[]string{"read"}, false},
+	}
+	for _, test := range tests {
+		if got := client.AllowsScopes(test.scopes); got != test.want {
+			t.Errorf("AllowsScopes(%v) = %v, want %v", test.scopes, got, test.want)
+		}
+	}
+}
+
+func TestOAuthTokenActive(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		token OAuthToken
+		want  bool
+	}{
+		{"valid", OAuthToken{Hash: "hash", ExpiresAt: now.Add(time.Minute)}, true},
+		{"expired", OAuthToken{Hash: "hash", ExpiresAt: now.Add(-time.Minute)}, false},
+		{"expiring now", OAuthToken{Hash: "hash", ExpiresAt: now}, false},
+		{"revoked", OAuthToken{Hash: "hash", ExpiresAt: now.Add(time.Minute), Revoked: true}, false},
+		{"not found", OAuthToken{ExpiresAt: now.Add(time.Minute)}, false},
+	}
+	for _, test := range tests {
+		if got := test.token.Active(now); got != test.want {
+			t.Errorf("%s: Active = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"api/src/password"
+	"errors"
+	"strings"
+)
+
+// Password - body of a password change
+type Password struct {
+	Current string `json:"current"`
+	New     string `json:"new"`
+}
+
+// Prepare - trim the passwords and check both are present
+func (p *Password) Prepare() error {
+	p.Current = strings.TrimSpace(p.Current)
+	p.New = strings.TrimSpace(p.New)
+	if p.Current == "" {
+		return errors.New("Current: invalid arguments")
+	}
+	if p.New == "" {
+		return errors.New("New: invalid arguments")
+	}
+	return nil
+}
+
+// Check - validate the new password against the policy for user, once the
+// current password was verified
+func (p *Password) Check(user User) error {
+	return password.Check(p.New, user.Nick, user.Email)
+}
@@ -0,0 +1,9 @@
+package models
+
+// Relationship - how the token's user and another user follow each other
+type Relationship struct {
+	UserID     uint64 `json:"user_id"`
+	Following  bool   `json:"following"`
+	FollowedBy bool   `json:"followed_by"`
+	Mutual     bool   `json:"mutual"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Session - a login, identified by the sid claim of its token
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     uint64    `json:"user_id,omitempty"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreateAt   time.Time `json:"CreateAt"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
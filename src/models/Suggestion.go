@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Suggestion - an account the user may want to follow
+type Suggestion struct {
+	User User `json:"user"`
+	// Mutuals - how many accounts the user follows already follow it
+	Mutuals    int       `json:"mutuals"`
+	FollowsYou bool      `json:"follows_you"`
+	Score      float64   `json:"score"`
+	ComputedAt time.Time `json:"computed_at"`
+}
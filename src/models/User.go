@@ -2,6 +2,7 @@ package models
 
 import (
 	"api/src/hash"
+	"api/src/password"
 	"errors"
 	"strings"
 	"time"
@@ -17,6 +18,12 @@ type User struct {
 	Email    string    `json:"email,omitempty"`
 	Password string    `json:"password,omitempty"`
 	CreateAt time.Time `json:"CreateAt,omitempty"`
+	// follows with active users, kept in step with the followers table, see
+	// UserRepo.FollowUser
+	FollowerCount  int `json:"follower_count"`
+	FollowingCount int `json:"following_count"`
+	// DeactivatedAt - set while a deleted account can still be restored
+	DeactivatedAt *time.Time `json:"-"`
 }
 
 func (user *User) Prepare(step string) error {
@@ -45,6 +52,11 @@ func (user *User) validate(step string) error {
 	if user.Password == "" && step == "cadastro" {
 		return errors.New("Password: invalid arguments")
 	}
+	if step == "cadastro" {
+		if err := password.Check(strings.TrimSpace(user.Password), user.Nick, user.Email); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,29 @@
+package notify
+
+import (
+	"api/src/models"
+	"api/src/repository"
+	"context"
+	"database/sql"
+	"log"
+)
+
+// Kinds of notification
+const (
+	ExportReady  = "export_ready"
+	ExportFailed = "export_failed"
+)
+
+// Send - store a notification for userID. Failing to notify never fails
+// the action that triggered it.
+func Send(ctx context.Context, db *sql.DB, userID uint64, kind string, message string, link string) {
+	err := repository.NewNotificationRepo(db).Create(ctx, models.Notification{
+		UserID:  userID,
+		Kind:    kind,
+		Message: message,
+		Link:    link,
+	})
+	if err != nil {
+		log.Printf("notify user %d of %s: %v", userID, kind, err)
+	}
+}
@@ -0,0 +1,88 @@
+package oidc
+
+import (
+	"api/src/config"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const flowCookie = "oidc_flow"
+
+// Flow - per-login secrets: state against CSRF, nonce against ID token
+// replay and the PKCE code verifier, and whether the login token should be
+// set as a cookie. They travel in an HttpOnly cookie so any API instance
+// can finish the login.
+type Flow struct {
+	State    string
+	Nonce    string
+	Verifier string
+	Cookie   bool
+}
+
+// NewFlow - random secrets for a new login
+func NewFlow(cookie bool) (Flow, error) {
+	var values [3]string
+	for i := range values {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return Flow{}, err
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(secret)
+	}
+	return Flow{State: values[0], Nonce: values[1], Verifier: values[2], Cookie: cookie}, nil
+}
+
+// SetCookie - remember the flow in the browser for ten minutes
+func (f Flow) SetCookie(w http.ResponseWriter, path string) {
+	mode := "0"
+	if f.Cookie {
+		mode = "1"
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     flowCookie,
+		Value:    f.State + "." + f.Nonce + "." + f.Verifier + "." + mode,
+		Path:     path,
+		Expires:  time.Now().Add(10 * time.Minute),
+		HttpOnly: true,
+		Secure:   config.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// FlowFromCookie - the flow started by this browser, checked against the
+// state the provider sent back
+func FlowFromCookie(r *http.Request, state string) (Flow, error) {
+	cookie, err := r.Cookie(flowCookie)
+	if err != nil {
+		return Flow{}, errors.New("oidc: login was not started or has expired")
+	}
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 4 || state == "" || parts[0] != state {
+		return Flow{}, errors.New("oidc: state mismatch")
+	}
+	return Flow{State: parts[0], Nonce: parts[1], Verifier: parts[2], Cookie: parts[3] == "1"}, nil
+}
+
+// ClearCookie - the flow can only be used once
+func ClearCookie(w http.ResponseWriter, path string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     flowCookie,
+		Value:    "",
+		Path:     path,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   config.CookieSecure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// challenge - S256 PKCE code challenge of the verifier
+func (f Flow) challenge() string {
+	sum := sha256.Sum256([]byte(f.Verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,160 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// Claims - identity asserted by the provider's ID token
+type Claims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// VerifyIDToken - check the ID token's signature against the provider's
+// JWKS, and its issuer, audience, expiry and nonce
+func (p *Provider) VerifyIDToken(ctx context.Context, rawToken string, nonce string) (Claims, error) {
+	token, err := jwt.Parse(rawToken, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("oidc: unexpected signing method %s", token.Method.Alg())
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(ctx, kid)
+	})
+	if err != nil {
+		return Claims{}, err
+	}
+	permissions, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return Claims{}, errors.New("oidc: invalid ID token")
+	}
+	// p.Issuer has no trailing slash, providers like Auth0 sign with one
+	if issuer, _ := permissions["iss"].(string); strings.TrimSuffix(issuer, "/") != p.Issuer {
+		return Claims{}, errors.New("oidc: ID token issuer mismatch")
+	}
+	if !audience(permissions, p.ClientID) {
+		return Claims{}, errors.New("oidc: ID token audience mismatch")
+	}
+	if _, ok := permissions["exp"]; !ok {
+		return Claims{}, errors.New("oidc: ID token has no expiry")
+	}
+	if tokenNonce, _ := permissions["nonce"].(string); tokenNonce != nonce {
+		return Claims{}, errors.New("oidc: ID token nonce mismatch")
+	}
+
+	claims := Claims{}
+	claims.Subject, _ = permissions["sub"].(string)
+	claims.Email, _ = permissions["email"].(string)
+	claims.Name, _ = permissions["name"].(string)
+	claims.PreferredUsername, _ = permissions["preferred_username"].(string)
+	switch verified := permissions["email_verified"].(type) {
+	case bool:
+		claims.EmailVerified = verified
+	case string:
+		claims.EmailVerified = verified == "true"
+	}
+	if claims.Subject == "" {
+		return Claims{}, errors.New("oidc: ID token has no subject")
+	}
+	return claims, nil
+}
+
+// publicKey - provider key by kid, refetching the JWKS once for a kid we
+// haven't seen, as providers rotate their keys
+func (p *Provider) publicKey(ctx context.Context, kid string) (interface{}, error) {
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var jwks struct {
+		Keys []struct {
+			KeyType string `json:"kty"`
+			KeyID   string `json:"kid"`
+			Curve   string `json:"crv"`
+			N       string `json:"n"`
+			E       string `json:"e"`
+			X       string `json:"x"`
+			Y       string `json:"y"`
+		} `json:"keys"`
+	}
+	if err = getJSON(ctx, discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+	for _, jwk := range jwks.Keys {
+		switch jwk.KeyType {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[jwk.KeyID] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			curve := map[string]elliptic.Curve{
+				"P-256": elliptic.P256(),
+				"P-384": elliptic.P384(),
+				"P-521": elliptic.P521(),
+			}[jwk.Curve]
+			x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+			y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+			if curve == nil || errX != nil || errY != nil {
+				continue
+			}
+			keys[jwk.KeyID] = &ecdsa.PublicKey{
+				Curve: curve,
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+	if key, ok = keys[kid]; !ok {
+		return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// audience - jwt-go v3 only understands a string aud, ID tokens may carry
+// an array
+func audience(permissions jwt.MapClaims, clientID string) bool {
+	switch aud := permissions["aud"].(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, value := range aud {
+			if value == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
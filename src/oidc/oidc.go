@@ -0,0 +1,173 @@
+package oidc
+
+import (
+	"api/src/config"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDisabled - no provider is configured
+var ErrDisabled = errors.New("OIDC login is not configured")
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Discovery - the parts of the provider's discovery document we use
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider - an external OpenID Connect identity provider
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+
+	mu        sync.Mutex
+	discovery *Discovery
+	keys      map[string]interface{}
+}
+
+var (
+	provider     *Provider
+	loadProvider sync.Once
+)
+
+// Default - the provider from OIDC_* settings, or ErrDisabled
+func Default() (*Provider, error) {
+	loadProvider.Do(func() {
+		if config.OIDCIssuer == "" || config.OIDCClientID == "" {
+			return
+		}
+		provider = &Provider{
+			Issuer:       strings.TrimSuffix(config.OIDCIssuer, "/"),
+			ClientID:     config.OIDCClientID,
+			ClientSecret: config.OIDCClientSecret,
+			RedirectURL:  config.OIDCRedirectURL,
+		}
+	})
+	if provider == nil {
+		return nil, ErrDisabled
+	}
+	return provider, nil
+}
+
+// Discover - fetch and cache the provider's discovery document
+func (p *Provider) Discover(ctx context.Context) (Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return *p.discovery, nil
+	}
+
+	var discovery Discovery
+	if err := getJSON(ctx, p.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return Discovery{}, err
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.Issuer {
+		return Discovery{}, fmt.Errorf("oidc: discovery issuer %q does not match %q", discovery.Issuer, p.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return Discovery{}, errors.New("oidc: incomplete discovery document")
+	}
+	p.discovery = &discovery
+	return discovery, nil
+}
+
+// AuthCodeURL - where to send the browser to start the login
+func (p *Provider) AuthCodeURL(ctx context.Context, flow Flow) (string, error) {
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.ClientID},
+		"redirect_uri":          {p.RedirectURL},
+		"scope":                 {"openid email profile"},
+		"state":                 {flow.State},
+		"nonce":                 {flow.Nonce},
+		"code_challenge":        {flow.challenge()},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange - trade the authorization code for the provider's ID token
+func (p *Provider) Exchange(ctx context.Context, code string, flow Flow) (string, error) {
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"client_id":     {p.ClientID},
+		"code_verifier": {flow.Verifier},
+	}
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oidc: token endpoint returned %d: %s", response.StatusCode, body)
+	}
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err = json.Unmarshal(body, &tokens); err != nil {
+		return "", err
+	}
+	if tokens.IDToken == "" {
+		return "", errors.New("oidc: token response has no id_token")
+	}
+	return tokens.IDToken, nil
+}
+
+func getJSON(ctx context.Context, uri string, data interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: GET %s returned %d", uri, response.StatusCode)
+	}
+	return json.NewDecoder(response.Body).Decode(data)
+}
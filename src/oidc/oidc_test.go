@@ -0,0 +1,269 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// standIn - a local identity provider serving discovery, JWKS and a token
+// endpoint that answers with idToken
+type standIn struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+	// issuer as the provider writes it, "" for the server URL
+	issuer  string
+	idToken string
+	// form posted to the token endpoint
+	form url.Values
+}
+
+func newStandIn(t *testing.T) *standIn {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &standIn{key: key, kid: "test-key"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{
+			Issuer:                s.issuerValue(),
+			AuthorizationEndpoint: s.server.URL + "/authorize",
+			TokenEndpoint:         s.server.URL + "/token",
+			JWKSURI:               s.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": s.kid,
+				"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		s.form = r.PostForm
+		if r.PostForm.Get("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": s.idToken})
+	})
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *standIn) issuerValue() string {
+	if s.issuer != "" {
+		return s.issuer
+	}
+	return s.server.URL
+}
+
+func (s *standIn) provider() *Provider {
+	return &Provider{
+		Issuer:      s.server.URL,
+		ClientID:    "client",
+		RedirectURL: "https://app.example/login/oidc/callback",
+	}
+}
+
+func (s *standIn) sign(t *testing.T, claims jwt.MapClaims, kid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestDiscover(t *testing.T) {
+	tests := []struct {
+		name    string
+		issuer  func(url string) string
+		wantErr bool
+	}{
+		{"same issuer", func(url string) string { return url }, false},
+		{"trailing slash", func(url string) string { return url + "/" }, false},
+		{"other issuer", func(string) string { return "https://evil.example" }, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newStandIn(t)
+			s.issuer = test.issuer(s.server.URL)
+			discovery, err := s.provider().Discover(context.Background())
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Discover: %v, want error %v", err, test.wantErr)
+			}
+			if err == nil && discovery.TokenEndpoint != s.server.URL+"/token" {
+				t.Errorf("TokenEndpoint = %q", discovery.TokenEndpoint)
+			}
+		})
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	valid := func(s *standIn) jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":            s.server.URL,
+			"aud":            "client",
+			"sub":            "subject-1",
+			"exp":            time.Now().Add(time.Minute).Unix(),
+			"nonce":          "nonce-1",
+			"email":          "alice@example.com",
+			"email_verified": true,
+		}
+	}
+	tests := []struct {
+		name    string
+		change  func(s *standIn, claims jwt.MapClaims)
+		kid     string
+		wantErr bool
+	}{
+		{"valid", func(*standIn, jwt.MapClaims) {}, "", false},
+		{"issuer with trailing slash", func(s *standIn, c jwt.MapClaims) { c["iss"] = s.server.URL + "/" }, "", false},
+		{"audience array", func(_ *standIn, c jwt.MapClaims) { c["aud"] = []string{"other", "client"} }, "", false},
+		{"other issuer", func(_ *standIn, c jwt.MapClaims) { c["iss"] = "https://evil.example" }, "", true},
+		{"other audience", func(_ *standIn, c jwt.MapClaims) { c["aud"] = "other" }, "", true},
+		{"wrong nonce", func(_ *standIn, c jwt.MapClaims) { c["nonce"] = "replayed" }, "", true},
+		{"no nonce", func(_ *standIn, c jwt.MapClaims) { delete(c, "nonce") }, "", true},
+		{"expired", func(_ *standIn, c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, "", true},
+		{"no expiry", func(_ *standIn, c jwt.MapClaims) { delete(c, "exp") }, "", true},
+		{"no subject", func(_ *standIn, c jwt.MapClaims) { delete(c, "sub") }, "", true},
+		{"unknown key", func(*standIn, jwt.MapClaims) {}, "rotated-away", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newStandIn(t)
+			claims := valid(s)
+			test.change(s, claims)
+			kid := test.kid
+			if kid == "" {
+				kid = s.kid
+			}
+			got, err := s.provider().VerifyIDToken(context.Background(), s.sign(t, claims, kid), "nonce-1")
+			if (err != nil) != test.wantErr {
+				t.Fatalf("VerifyIDToken: %v, want error %v", err, test.wantErr)
+			}
+			if err == nil && (got.Subject != "subject-1" || got.Email != "alice@example.com" || !got.EmailVerified) {
+				t.Errorf("claims = %+v", got)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenForeignKey(t *testing.T) {
+	s := newStandIn(t)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": s.server.URL, "aud": "client", "sub": "subject-1", "nonce": "nonce-1",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	token.Header["kid"] = s.kid
+	signed, err := token.SignedString(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.provider().VerifyIDToken(context.Background(), signed, "nonce-1"); err == nil {
+		t.Error("accepted a token signed by another key")
+	}
+}
+
+func TestAuthCodeURLAndExchange(t *testing.T) {
+	s := newStandIn(t)
+	provider := s.provider()
+	flow, err := NewFlow(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redirect, err := provider.AuthCodeURL(context.Background(), flow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, _ := url.Parse(redirect)
+	for param, want := range map[string]string{
+		"state":                 flow.State,
+		"nonce":                 flow.Nonce,
+		"code_challenge":        flow.challenge(),
+		"code_challenge_method": "S256",
+		"client_id":             "client",
+	} {
+		if got := query.Query().Get(param); got != want {
+			t.Errorf("%s = %q, want %q", param, got, want)
+		}
+	}
+
+	s.idToken = "id-token"
+	idToken, err := provider.Exchange(context.Background(), "good-code", flow)
+	if err != nil || idToken != "id-token" {
+		t.Fatalf("Exchange = %q, %v", idToken, err)
+	}
+	if s.form.Get("code_verifier") != flow.Verifier {
+		t.Errorf("code_verifier = %q, want the flow's", s.form.Get("code_verifier"))
+	}
+	if _, err = provider.Exchange(context.Background(), "bad-code", flow); err == nil {
+		t.Error("Exchange accepted a refused code")
+	}
+}
+
+func TestFlowFromCookie(t *testing.T) {
+	for _, cookie := range []bool{false, true} {
+		flow, err := NewFlow(cookie)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorder := httptest.NewRecorder()
+		flow.SetCookie(recorder, "/login/oidc/callback")
+		set := recorder.Result().Cookies()
+
+		tests := []struct {
+			name    string
+			cookies []*http.Cookie
+			state   string
+			wantErr bool
+		}{
+			{"matching state", set, flow.State, false},
+			{"other state", set, "forged", true},
+			{"empty state", set, "", true},
+			{"no cookie", nil, flow.State, true},
+			{"tampered cookie", []*http.Cookie{{Name: flowCookie, Value: strings.Repeat(".", 5)}}, "", true},
+		}
+		for _, test := range tests {
+			request := httptest.NewRequest(http.MethodGet, "/login/oidc/callback", nil)
+			for _, c := range test.cookies {
+				request.AddCookie(c)
+			}
+			got, err := FlowFromCookie(request, test.state)
+			if (err != nil) != test.wantErr {
+				t.Errorf("%s: FlowFromCookie = %v, want error %v", test.name, err, test.wantErr)
+			}
+			if err == nil && got != flow {
+				t.Errorf("%s: flow = %+v, want %+v", test.name, got, flow)
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package password
+
+import (
+	"api/src/config"
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Load - check BREACHED_PASSWORDS_FILE can be searched, at startup rather
+// than on the first signup
+func Load() error {
+	path := config.BreachedPasswordsFile
+	if path == "" {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if _, ok := lineDigest(line); !ok {
+		return fmt.Errorf("password: %s does not start with a SHA1:COUNT line", path)
+	}
+	return nil
+}
+
+// Breached - whether password is in BREACHED_PASSWORDS_FILE, looked up by
+// SHA-1 without loading the corpus. The path is either
+//   - a directory of range files named after the 5 character hash prefix
+//     ("21BD1.txt") holding "SUFFIX:COUNT" lines, what the k-anonymity range
+//     API serves and the pwned passwords downloader writes, or
+//   - one "SHA1:COUNT" file sorted by hash, searched with seeks.
+//
+// Without a path nothing is considered breached.
+func Breached(password string) (bool, error) {
+	path := config.BreachedPasswordsFile
+	if path == "" {
+		return false, nil
+	}
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if info.IsDir() {
+		return inRange(filepath.Join(path, digest[:5]+".txt"), digest[5:])
+	}
+	return inSorted(path, digest)
+}
+
+// inRange - whether a range file lists suffix, a missing file is an empty range
+func inRange(path string, suffix string) (bool, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.SplitN(scanner.Text(), ":", 2)[0]
+		if strings.EqualFold(strings.TrimSpace(line), suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// inSorted - binary search a hash-ordered file for digest. Offsets are
+// searched for the first line whose hash isn't below digest.
+func inSorted(path string, digest string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	lo, hi := int64(0), info.Size()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		line, err := lineAt(file, mid)
+		if err != nil {
+			return false, err
+		}
+		if found, ok := lineDigest(line); !ok || found >= digest {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	line, err := lineAt(file, lo)
+	if err != nil {
+		return false, err
+	}
+	found, ok := lineDigest(line)
+	return ok && found == digest, nil
+}
+
+// lineAt - the first whole line starting at or after offset, empty at the
+// end of the file
+func lineAt(file *os.File, offset int64) (string, error) {
+	start := offset
+	if offset > 0 {
+		// start one byte early so a line starting exactly at offset is kept
+		start--
+	}
+	reader := bufio.NewReaderSize(io.NewSectionReader(file, start, 1<<62), 128)
+	if offset > 0 {
+		if _, err := reader.ReadString('\n'); err != nil {
+			if err == io.EOF {
+				return "", nil
+			}
+			return "", err
+		}
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return line, nil
+}
+
+// lineDigest - the upper case hash of a "SHA1:COUNT" line
+func lineDigest(line string) (string, bool) {
+	digest := strings.ToUpper(strings.TrimSpace(strings.SplitN(line, ":", 2)[0]))
+	if len(digest) != 40 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(digest); err != nil {
+		return "", false
+	}
+	return digest, true
+}
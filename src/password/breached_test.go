@@ -0,0 +1,184 @@
+package password
+
+import (
+	"api/src/config"
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func digest(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func usePath(t *testing.T, path string) {
+	t.Helper()
+	previous := config.BreachedPasswordsFile
+	t.Cleanup(func() { config.BreachedPasswordsFile = previous })
+	config.BreachedPasswordsFile = path
+}
+
+var breached = []string{"password", "123456", "qwerty", "letmein", "Tr0ub4dor&3", "monkey", "dragon"}
+
+// sortedFile - the ordered-by-hash download, with CRLF line endings
+func sortedFile(t *testing.T) string {
+	var lines []string
+	for i, password := range breached {
+		lines = append(lines, digest(password)+":"+strings.Repeat("9", i+1))
+	}
+	sort.Strings(lines)
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// rangeDir - one file per hash prefix, like the range API responses
+func rangeDir(t *testing.T) string {
+	dir := t.TempDir()
+	for _, password := range breached {
+		d := digest(password)
+		file, err := os.OpenFile(filepath.Join(dir, d[:5]+".txt"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.WriteString(strings.ToLower(d[5:]) + ":3\n")
+		file.Close()
+	}
+	return dir
+}
+
+func TestBreached(t *testing.T) {
+	sources := map[string]func(*testing.T) string{
+		"sorted file": sortedFile,
+		"range dir":   rangeDir,
+	}
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"password", true},
+		{"dragon", true},
+		{"Tr0ub4dor&3", true},
+		{"tr0ub4dor&3", false},
+		{"correct horse battery staple", false},
+		{"", false},
+	}
+	// the smallest and largest hashes exercise both ends of the search
+	sorted := append([]string{}, breached...)
+	sort.Slice(sorted, func(i, j int) bool { return digest(sorted[i]) < digest(sorted[j]) })
+	tests = append(tests,
+		struct {
+			password string
+			want     bool
+		}{sorted[0], true},
+		struct {
+			password string
+			want     bool
+		}{sorted[len(sorted)-1], true},
+	)
+
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			usePath(t, source(t))
+			if err := Load(); err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			for _, test := range tests {
+				got, err := Breached(test.password)
+				if err != nil || got != test.want {
+					t.Errorf("Breached(%q) = %v, %v; want %v", test.password, got, err, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestBreachedWithoutCorpus(t *testing.T) {
+	usePath(t, "")
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Breached("password"); got || err != nil {
+		t.Errorf("Breached = %v, %v; want false, nil", got, err)
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	dir := t.TempDir()
+	notHashes := filepath.Join(dir, "words.txt")
+	os.WriteFile(notHashes, []byte("password\n123456\n"), 0o600)
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"missing", filepath.Join(dir, "missing.txt")},
+		{"not a hash file", notHashes},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			usePath(t, test.path)
+			if err := Load(); err == nil {
+				t.Error("Load accepted it")
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	usePath(t, sortedFile(t))
+	min, max, classes := config.PasswordMinLength, config.PasswordMaxBytes, config.PasswordCharacterClasses
+	t.Cleanup(func() {
+		config.PasswordMinLength, config.PasswordMaxBytes, config.PasswordCharacterClasses = min, max, classes
+	})
+	config.PasswordMinLength, config.PasswordMaxBytes, config.PasswordCharacterClasses = 8, 72, 3
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{"good", "Blue-Kettle-42", false},
+		{"too short", "Ab1!", true},
+		{"too long", strings.Repeat("Ab1!", 19), true},
+		{"two classes", "bluekettle42", true},
+		{"contains nick", "Alice-Rocks-42", true},
+		{"contains email local part", "Wonder-Land-42x", true},
+		{"breached", "Tr0ub4dor&3", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Check(test.password, "alice", "wonder@example.com")
+			if (err != nil) != test.wantErr {
+				t.Errorf("Check(%q) = %v, want error %v", test.password, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckBreachErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     func(t *testing.T) string
+		password string
+		want     error
+	}{
+		{"breach hit", sortedFile, "Tr0ub4dor&3", ErrBreached},
+		{"no hit", sortedFile, "Blue-Kettle-42", nil},
+		{"corpus gone", func(t *testing.T) string { return filepath.Join(t.TempDir(), "gone.txt") }, "Tr0ub4dor&3", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			usePath(t, test.path(t))
+			if err := Check(test.password); err != test.want {
+				t.Errorf("Check = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
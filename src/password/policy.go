@@ -0,0 +1,69 @@
+package password
+
+import (
+	"api/src/config"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// ErrBreached - the password is listed in the breached password corpus
+var ErrBreached = errors.New("Password: appears in a known data breach, choose another one")
+
+// Check - validate a new password against the policy and the breached
+// password corpus. identities (nick, email...) may not appear in it.
+func Check(password string, identities ...string) error {
+	if len(password) < config.PasswordMinLength {
+		return fmt.Errorf("Password: must have at least %d characters", config.PasswordMinLength)
+	}
+	// bcrypt ignores everything after 72 bytes
+	if len(password) > config.PasswordMaxBytes {
+		return fmt.Errorf("Password: must have at most %d bytes", config.PasswordMaxBytes)
+	}
+	if classes := characterClasses(password); classes < config.PasswordCharacterClasses {
+		return fmt.Errorf(
+			"Password: must mix at least %d of lowercase, uppercase, digits and symbols",
+			config.PasswordCharacterClasses,
+		)
+	}
+	lower := strings.ToLower(password)
+	for _, identity := range identities {
+		identity = strings.ToLower(strings.TrimSpace(identity))
+		if at := strings.Index(identity, "@"); at > 0 {
+			identity = identity[:at]
+		}
+		if len(identity) >= 3 && strings.Contains(lower, identity) {
+			return errors.New("Password: must not contain your nick or email")
+		}
+	}
+	// a corpus that can't be read fails open: the error would name its
+	// path, and Load already checked it at startup
+	breached, err := Breached(password)
+	if err != nil {
+		log.Printf("password: breached lookup: %v", err)
+		return nil
+	}
+	if breached {
+		return ErrBreached
+	}
+	return nil
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore - process-local Store, fine for a single API instance
+type MemoryStore struct {
+	mu        sync.Mutex
+	keys      map[string]*windows
+	lastPrune time.Time
+}
+
+// windows - hits per window start of one key
+type windows struct {
+	length time.Duration
+	hits   map[time.Time]int
+}
+
+// NewMemoryStore - create an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: map[string]*windows{}}
+}
+
+func (s *MemoryStore) Hit(ctx context.Context, key string, start time.Time, window time.Duration) (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(start)
+
+	counts, ok := s.keys[key]
+	if !ok {
+		counts = &windows{hits: map[time.Time]int{}}
+		s.keys[key] = counts
+	}
+	counts.length = window
+	previousStart := start.Add(-window)
+	for windowStart := range counts.hits {
+		if windowStart.Before(previousStart) {
+			delete(counts.hits, windowStart)
+		}
+	}
+	counts.hits[start]++
+	return counts.hits[start], counts.hits[previousStart], nil
+}
+
+// prune - at most once a minute, forget keys with no hits in their current
+// or previous window, so callers seen once don't stay forever. now is the
+// start of the window being hit, never later than the actual time.
+func (s *MemoryStore) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < time.Minute {
+		return
+	}
+	s.lastPrune = now
+	for key, counts := range s.keys {
+		for windowStart := range counts.hits {
+			if !windowStart.After(now.Add(-2 * counts.length)) {
+				delete(counts.hits, windowStart)
+			}
+		}
+		if len(counts.hits) == 0 {
+			delete(s.keys, key)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Rule - allow at most Requests per Window. A zero Rule means "use the
+// configured defaults" and a negative Requests disables limiting.
+type Rule struct {
+	Requests int
+	Window   time.Duration
+}
+
+// Validate - a rule must let requests through in a non-empty window, use a
+// negative Requests to disable limiting
+func (rule Rule) Validate() error {
+	if rule.Requests == 0 {
+		return errors.New("ratelimit: a rule must allow at least one request")
+	}
+	if rule.Window <= 0 {
+		return fmt.Errorf("ratelimit: invalid window %s", rule.Window)
+	}
+	return nil
+}
+
+// longest - the longest window of the rules in use, counters older than two
+// of them can't matter to any route
+var longest = struct {
+	sync.Mutex
+	window time.Duration
+}{}
+
+// Track - note a rule in use, for LongestWindow
+func Track(rule Rule) {
+	longest.Lock()
+	defer longest.Unlock()
+	if rule.Window > longest.window {
+		longest.window = rule.Window
+	}
+}
+
+// LongestWindow - the longest window of the rules tracked so far
+func LongestWindow() time.Duration {
+	longest.Lock()
+	defer longest.Unlock()
+	return longest.window
+}
+
+// Store - hit counters per key and fixed window, in memory or in SQL
+type Store interface {
+	// Hit - count one request in the window starting at start and return
+	// the counts of that window and of the one before it
+	Hit(ctx context.Context, key string, start time.Time, window time.Duration) (current int, previous int, err error)
+}
+
+// Result - outcome of a hit, enough to fill the RateLimit-* headers
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+}
+
+// Allow - count a request against key using a sliding window: the previous
+// fixed window's hits are weighted by how much of it still overlaps.
+func Allow(ctx context.Context, store Store, key string, rule Rule, now time.Time) (Result, error) {
+	start := now.Truncate(rule.Window)
+	current, previous, err := store.Hit(ctx, key, start, rule.Window)
+	if err != nil {
+		return Result{}, err
+	}
+	elapsed := now.Sub(start)
+	overlap := float64(rule.Window-elapsed) / float64(rule.Window)
+	used := int(math.Ceil(float64(previous)*overlap)) + current
+
+	result := Result{
+		Allowed:   used <= rule.Requests,
+		Limit:     rule.Requests,
+		Remaining: rule.Requests - used,
+		Reset:     rule.Window - elapsed,
+	}
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	return result, nil
+}
@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	rule := Rule{Requests: 10, Window: time.Minute}
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		previous      int
+		current       int
+		at            time.Duration
+		wantAllowed   bool
+		wantRemaining int
+	}{
+		{"empty", 0, 0, 0, true, 9},
+		{"last allowed", 0, 9, 30 * time.Second, true, 0},
+		{"over the limit", 0, 10, 30 * time.Second, false, 0},
+		{"previous window fully counted at its end", 10, 0, 0, false, 0},
+		{"previous window half counted halfway", 10, 0, 30 * time.Second, true, 4},
+		{"previous window almost forgotten", 10, 0, 59 * time.Second, true, 8},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			for i := 0; i < test.previous; i++ {
+				store.Hit(context.Background(), "k", start.Add(-time.Minute), time.Minute)
+			}
+			for i := 0; i < test.current; i++ {
+				store.Hit(context.Background(), "k", start, time.Minute)
+			}
+			result, err := Allow(context.Background(), store, "k", rule, start.Add(test.at))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Allowed != test.wantAllowed || result.Remaining != test.wantRemaining {
+				t.Errorf("Allow = %+v, want allowed %v remaining %d", result, test.wantAllowed, test.wantRemaining)
+			}
+			if result.Reset != time.Minute-test.at {
+				t.Errorf("Reset = %s, want %s", result.Reset, time.Minute-test.at)
+			}
+		})
+	}
+}
+
+func TestAllowSeparatesKeys(t *testing.T) {
+	store := NewMemoryStore()
+	rule := Rule{Requests: 1, Window: time.Minute}
+	now := time.Now()
+	for _, key := range []string{"a", "b"} {
+		if result, _ := Allow(context.Background(), store, key, rule, now); !result.Allowed {
+			t.Errorf("first request of %s refused", key)
+		}
+	}
+	if result, _ := Allow(context.Background(), store, "a", rule, now); result.Allowed {
+		t.Error("second request of a allowed")
+	}
+}
+
+func TestMemoryStorePrunes(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	store.Hit(context.Background(), "short", start, time.Minute)
+	store.Hit(context.Background(), "long", start, time.Hour)
+
+	// still the previous window of "short"
+	store.Hit(context.Background(), "other", start.Add(time.Minute), time.Minute)
+	if _, ok := store.keys["short"]; !ok {
+		t.Fatal("pruned a key whose hits still count")
+	}
+	store.Hit(context.Background(), "other", start.Add(3*time.Minute), time.Minute)
+	if _, ok := store.keys["short"]; ok {
+		t.Error("kept a key with no hits in its last two windows")
+	}
+	if _, ok := store.keys["long"]; !ok {
+		t.Error("pruned a key inside its own longer window")
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	tests := []struct {
+		rule    Rule
+		wantErr bool
+	}{
+		{Rule{Requests: 1, Window: time.Second}, false},
+		{Rule{Requests: 0, Window: time.Minute}, true},
+		{Rule{Requests: 10, Window: 0}, true},
+		{Rule{Requests: 10, Window: -time.Minute}, true},
+	}
+	for _, test := range tests {
+		if err := test.rule.Validate(); (err != nil) != test.wantErr {
+			t.Errorf("%+v.Validate() = %v, want error %v", test.rule, err, test.wantErr)
+		}
+	}
+}
+
+func TestLongestWindow(t *testing.T) {
+	defer func(window time.Duration) { longest.window = window }(longest.window)
+	longest.window = 0
+	for _, rule := range []Rule{
+		{Requests: 10, Window: time.Minute},
+		{Requests: 5, Window: time.Hour},
+		{Requests: 60, Window: time.Second},
+	} {
+		Track(rule)
+	}
+	if got := LongestWindow(); got != time.Hour {
+		t.Errorf("LongestWindow = %v, want %v", got, time.Hour)
+	}
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// AccessTokenRepo struct to create a repository
+type AccessTokenRepo struct {
+	db *sql.DB
+}
+
+// NewAccessTokenRepo - create a new access token's repository
+func NewAccessTokenRepo(db *sql.DB) *AccessTokenRepo {
+	return &AccessTokenRepo{db}
+}
+
+// Create - store a new token, token.Hash must already be set
+func (repo AccessTokenRepo) Create(ctx context.Context, token models.AccessToken) (uint64, error) {
+	statement, err := repo.db.PrepareContext(ctx,
+		"INSERT INTO access_tokens (user_id, name, token_hash, scopes, expires_at) VALUES (?, ?, ?, ?, ?)",
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer statement.Close()
+
+	var expiresAt sql.NullTime
+	if token.ExpiresAt != nil {
+		expiresAt = nullTime(*token.ExpiresAt)
+	}
+	result, err := statement.ExecContext(ctx,
+		token.UserID, token.Name, token.Hash, strings.Join(token.Scopes, " "), expiresAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	ID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(ID), nil
+}
+
+// FindByUser - all tokens of an user
+func (repo AccessTokenRepo) FindByUser(ctx context.Context, userID uint64) ([]models.AccessToken, error) {
+	rows, err := repo.db.QueryContext(ctx, `
+	   SELECT id, user_id, name, scopes, expires_at, last_used_at, createAt
+	   FROM access_tokens WHERE user_id = ? ORDER BY id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.AccessToken{}
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// FindByHash - the token with this hash, ID is zero when there is none
+func (repo AccessTokenRepo) FindByHash(ctx context.Context, hash string) (models.AccessToken, error) {
+	rows, err := repo.db.QueryContext(ctx, `
+	   SELECT t.id, t.user_id, t.name, t.scopes, t.expires_at, t.last_used_at, t.createAt
+	   FROM access_tokens t INNER JOIN users u ON (u.id = t.user_id)
+	   WHERE t.token_hash = ? AND u.deactivatedAt IS NULL
+	`, hash)
+	if err != nil {
+		return models.AccessToken{}, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return scanAccessToken(rows)
+	}
+	return models.AccessToken{}, rows.Err()
+}
+
+// Touch - record the token's last use
+func (repo AccessTokenRepo) Touch(ctx context.Context, ID uint64, usedAt time.Time) error {
+	_, err := repo.db.ExecContext(ctx, "UPDATE access_tokens SET last_used_at = ? WHERE id = ?", usedAt, ID)
+	return err
+}
+
+// Delete - revoke a token of userID, false when it doesn't exist
+func (repo AccessTokenRepo) Delete(ctx context.Context, userID uint64, ID uint64) (bool, error) {
+	statement, err := repo.db.PrepareContext(ctx, "DELETE FROM access_tokens WHERE id = ? AND user_id = ?")
+	if err != nil {
+		return false, err
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, ID, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func scanAccessToken(rows *sql.Rows) (models.AccessToken, error) {
+	var token models.AccessToken
+	var scopes string
+	var expiresAt, lastUsedAt sql.NullTime
+	if err := rows.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&scopes,
+		&expiresAt,
+		&lastUsedAt,
+		&token.CreateAt,
+	); err != nil {
+		return models.AccessToken{}, err
+	}
+	token.Scopes = strings.Fields(scopes)
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	return token, nil
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// AuditRepo - append-only audit log, entries are never updated
+type AuditRepo struct {
+	db *sql.DB
+}
+
+// NewAuditRepo - create a new audit's repository
+func NewAuditRepo(db *sql.DB) *AuditRepo {
+	return &AuditRepo{db}
+}
+
+// Create - append an entry
+func (repo AuditRepo) Create(ctx context.Context, entry models.AuditEntry) error {
+	statement, err := repo.db.PrepareContext(ctx, `
+	   INSERT INTO audit_log (actor_id, action, target_type, target_id, ip, user_agent, changes)
+	   VALUES (?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	var actorID sql.NullInt64
+	if entry.ActorID != 0 {
+		actorID = sql.NullInt64{Int64: int64(entry.ActorID), Valid: true}
+	}
+	var changes sql.NullString
+	if len(entry.Changes) > 0 {
+		changes = sql.NullString{String: string(entry.Changes), Valid: true}
+	}
+	_, err = statement.ExecContext(ctx,
+		actorID, entry.Action, entry.TargetType, entry.TargetID, entry.IP, entry.UserAgent, changes,
+	)
+	return err
+}
+
+// Find - a page of entries matching filter, newest first
+func (repo AuditRepo) Find(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	rows, err := repo.query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Each - stream every entry matching filter to fn, ignoring the page, so
+// exports don't hold the whole log in memory
+func (repo AuditRepo) Each(ctx context.Context, filter models.AuditFilter, fn func(models.AuditEntry) error) error {
+	filter.Limit = 0
+	rows, err := repo.query(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err = fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (repo AuditRepo) query(ctx context.Context, filter models.AuditFilter) (*sql.Rows, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.ActorID != 0 {
+		conditions = append(conditions, "actor_id = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.TargetType != "" {
+		conditions = append(conditions, "target_type = ?")
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		conditions = append(conditions, "target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "createAt >= ?")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "createAt < ?")
+		args = append(args, filter.To)
+	}
+
+	query := "SELECT id, actor_id, action, target_type, target_id, ip, user_agent, changes, createAt FROM audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Page*filter.Limit)
+	}
+	return repo.db.QueryContext(ctx, query, args...)
+}
+
+func scanAuditEntry(rows *sql.Rows) (models.AuditEntry, error) {
+	var entry models.AuditEntry
+	var actorID sql.NullInt64
+	var changes sql.NullString
+	if err := rows.Scan(
+		&entry.ID,
+		&actorID,
+		&entry.Action,
+		&entry.TargetType,
+		&entry.TargetID,
+		&entry.IP,
+		&entry.UserAgent,
+		&changes,
+		&entry.CreateAt,
+	); err != nil {
+		return models.AuditEntry{}, err
+	}
+	entry.ActorID = uint64(actorID.Int64)
+	if changes.Valid {
+		entry.Changes = []byte(changes.String)
+	}
+	return entry, nil
+}
+
+// Purge - drop entries older than before, the retention period
+func (repo AuditRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	result, err := repo.db.ExecContext(ctx, "DELETE FROM audit_log WHERE createAt < ?", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAuditFilter(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewAuditRepo(db)
+	IDs := createUsers(t, db, 2)
+	entries := []models.AuditEntry{
+		{ActorID: IDs[0], Action: "login", TargetType: "session", TargetID: "a"},
+		{ActorID: IDs[1], Action: "login", TargetType: "session", TargetID: "b"},
+		{ActorID: IDs[0], Action: "user_updated", TargetType: "user", TargetID: "1"},
+		{Action: "login_failed", TargetType: "user", TargetID: "1"},
+	}
+	for _, entry := range entries {
+		if err := repo.Create(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter models.AuditFilter
+		// target ids, newest first
+		want []string
+	}{
+		{"everything", models.AuditFilter{}, []string{"1", "1", "b", "a"}},
+		{"actor", models.AuditFilter{ActorID: IDs[0]}, []string{"1", "a"}},
+		{"action", models.AuditFilter{Action: "login"}, []string{"b", "a"}},
+		{"target", models.AuditFilter{TargetType: "user", TargetID: "1"}, []string{"1", "1"}},
+		{"actor and action", models.AuditFilter{ActorID: IDs[1], Action: "login"}, []string{"b"}},
+		{"page", models.AuditFilter{Limit: 2, Page: 1}, []string{"b", "a"}},
+		{"from tomorrow", models.AuditFilter{From: time.Now().Add(24 * time.Hour)}, []string{}},
+		{"until yesterday", models.AuditFilter{To: time.Now().Add(-24 * time.Hour)}, []string{}},
+	}
+	for _, test := range tests {
+		found, err := repo.Find(ctx, test.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		got := []string{}
+		for _, entry := range found {
+			got = append(got, entry.TargetID)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Find = %v, want %v", test.name, got, test.want)
+		}
+	}
+	if found, err := repo.Find(ctx, models.AuditFilter{Action: "login_failed"}); err != nil || len(found) != 1 || found[0].ActorID != 0 {
+		t.Errorf("entry without an actor: %+v, %v", found, err)
+	}
+}
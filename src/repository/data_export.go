@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// DataExportRepo struct to create a repository
+type DataExportRepo struct {
+	db *sql.DB
+}
+
+// NewDataExportRepo - create a new data export's repository
+func NewDataExportRepo(db *sql.DB) *DataExportRepo {
+	return &DataExportRepo{db}
+}
+
+// Create - store a pending export leased to owner until leaseUntil, false
+// when the user already has one pending
+func (repo DataExportRepo) Create(ctx context.Context, export models.DataExport, owner string, leaseUntil time.Time) (bool, error) {
+	statement, err := repo.db.PrepareContext(ctx, `
+	   INSERT INTO data_exports (id, user_id, status, claimed_by, lease_until)
+	   SELECT ?, ?, ?, ?, ? FROM DUAL
+	   WHERE NOT EXISTS (SELECT 1 FROM data_exports WHERE user_id = ? AND status = ?)
+	`)
+	if err != nil {
+		return false, err
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx,
+		export.ID, export.UserID, models.ExportPending, owner, leaseUntil,
+		export.UserID, models.ExportPending,
+	)
+	if err != nil {
+		return false, err
+	}
+	created, err := result.RowsAffected()
+	return created > 0, err
+}
+
+// FindPending - exports of an user still being built
+func (repo DataExportRepo) FindPending(ctx context.Context, userID uint64) ([]models.DataExport, error) {
+	return repo.pending(ctx, `
+	   SELECT id, user_id, status, createAt FROM data_exports
+	   WHERE status = ? AND user_id = ? ORDER BY createAt
+	`, models.ExportPending, userID)
+}
+
+// FindLapsed - pending exports whose owner's lease lapsed before now, the
+// instance building them is gone
+func (repo DataExportRepo) FindLapsed(ctx context.Context, now time.Time) ([]models.DataExport, error) {
+	return repo.pending(ctx, `
+	   SELECT id, user_id, status, createAt FROM data_exports
+	   WHERE status = ? AND (lease_until IS NULL OR lease_until < ?) ORDER BY createAt
+	`, models.ExportPending, now)
+}
+
+// Claim - lease a pending export to owner until leaseUntil, when it is
+// already its own or the previous lease lapsed before now. False when
+// another instance holds it or it isn't pending anymore.
+func (repo DataExportRepo) Claim(ctx context.Context, ID string, owner string, leaseUntil time.Time, now time.Time) (bool, error) {
+	if _, err := repo.db.ExecContext(ctx, `
+	   UPDATE data_exports SET claimed_by = ?, lease_until = ?
+	   WHERE id = ? AND status = ?
+	   AND (claimed_by = ? OR lease_until IS NULL OR lease_until < ?)
+	`, owner, leaseUntil, ID, models.ExportPending, owner, now); err != nil {
+		return false, err
+	}
+	// read back rather than trust RowsAffected, renewing a lease with the
+	// same values changes no row
+	var claimedBy string
+	err := repo.db.QueryRowContext(ctx,
+		"SELECT claimed_by FROM data_exports WHERE id = ? AND status = ?", ID, models.ExportPending,
+	).Scan(&claimedBy)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return claimedBy == owner, err
+}
+
+func (repo DataExportRepo) pending(ctx context.Context, query string, args ...interface{}) ([]models.DataExport, error) {
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exports []models.DataExport
+	for rows.Next() {
+		var export models.DataExport
+		if err = rows.Scan(&export.ID, &export.UserID, &export.Status, &export.CreateAt); err != nil {
+			return nil, err
+		}
+		exports = append(exports, export)
+	}
+	return exports, rows.Err()
+}
+
+// Find - export by id, ID is empty when there is none
+func (repo DataExportRepo) Find(ctx context.Context, ID string) (models.DataExport, error) {
+	var export models.DataExport
+	var expiresAt, completedAt sql.NullTime
+	err := repo.db.QueryRowContext(ctx, `
+	   SELECT id, user_id, status, error, file_key, expires_at, createAt, completed_at
+	   FROM data_exports WHERE id = ?
+	`, ID).Scan(
+		&export.ID,
+		&export.UserID,
+		&export.Status,
+		&export.Error,
+		&export.FileKey,
+		&expiresAt,
+		&export.CreateAt,
+		&completedAt,
+	)
+	if err == sql.ErrNoRows {
+		return models.DataExport{}, nil
+	}
+	if err != nil {
+		return models.DataExport{}, err
+	}
+	if expiresAt.Valid {
+		export.ExpiresAt = &expiresAt.Time
+	}
+	if completedAt.Valid {
+		export.CompletedAt = &completedAt.Time
+	}
+	return export, nil
+}
+
+// Complete - mark an export ready to download until expiresAt
+func (repo DataExportRepo) Complete(ctx context.Context, ID string, fileKey string, expiresAt time.Time) error {
+	_, err := repo.db.ExecContext(ctx, `
+	   UPDATE data_exports SET status = ?, file_key = ?, expires_at = ?, completed_at = ?
+	   WHERE id = ?
+	`, models.ExportReady, fileKey, expiresAt, time.Now(), ID)
+	return err
+}
+
+// Fail - mark an export failed
+func (repo DataExportRepo) Fail(ctx context.Context, ID string, reason string) error {
+	_, err := repo.db.ExecContext(ctx,
+		"UPDATE data_exports SET status = ?, error = ?, completed_at = ? WHERE id = ?",
+		models.ExportFailed, reason, time.Now(), ID,
+	)
+	return err
+}
+
+// Expired - exports whose download link expired before the given time, and
+// failed exports that failed before failedBefore
+func (repo DataExportRepo) Expired(ctx context.Context, before time.Time, failedBefore time.Time) ([]models.DataExport, error) {
+	rows, err := repo.db.QueryContext(ctx,
+		"SELECT id, file_key FROM data_exports WHERE expires_at < ? OR (status = ? AND completed_at < ?)",
+		before, models.ExportFailed, failedBefore,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exports []models.DataExport
+	for rows.Next() {
+		var export models.DataExport
+		if err = rows.Scan(&export.ID, &export.FileKey); err != nil {
+			return nil, err
+		}
+		exports = append(exports, export)
+	}
+	return exports, rows.Err()
+}
+
+// Delete - remove an export record
+func (repo DataExportRepo) Delete(ctx context.Context, ID string) error {
+	_, err := repo.db.ExecContext(ctx, "DELETE FROM data_exports WHERE id = ?", ID)
+	return err
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"database/sql"
+)
+
+// IdentityRepo - links between users and external identity provider accounts
+type IdentityRepo struct {
+	db *sql.DB
+}
+
+// NewIdentityRepo - create a new identity's repository
+func NewIdentityRepo(db *sql.DB) *IdentityRepo {
+	return &IdentityRepo{db}
+}
+
+// FindUser - user linked to the provider's subject, zero when none
+func (repo IdentityRepo) FindUser(ctx context.Context, issuer string, subject string) (uint64, error) {
+	var userID uint64
+	err := repo.db.QueryRowContext(ctx,
+		"SELECT user_id FROM user_identities WHERE issuer = ? AND subject = ?",
+		issuer, subject,
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return userID, err
+}
+
+// Link - attach the provider's subject to userID
+func (repo IdentityRepo) Link(ctx context.Context, issuer string, subject string, userID uint64) error {
+	statement, err := repo.db.PrepareContext(ctx,
+		"INSERT INTO user_identities (issuer, subject, user_id) VALUES (?, ?, ?)",
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.ExecContext(ctx, issuer, subject, userID)
+	return err
+}
+
+// FindByUser - provider accounts linked to an user
+func (repo IdentityRepo) FindByUser(ctx context.Context, userID uint64) ([]models.Identity, error) {
+	rows, err := repo.db.QueryContext(ctx,
+		"SELECT issuer, subject, user_id, createAt FROM user_identities WHERE user_id = ? ORDER BY createAt",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []models.Identity{}
+	for rows.Next() {
+		var identity models.Identity
+		if err = rows.Scan(&identity.Issuer, &identity.Subject, &identity.UserID, &identity.CreateAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
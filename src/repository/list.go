@@ -0,0 +1,301 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"database/sql"
+)
+
+// ListRepo struct to create a repository
+type ListRepo struct {
+	db *sql.DB
+}
+
+// NewListRepo - create a new list's repository
+func NewListRepo(db *sql.DB) *ListRepo {
+	return &ListRepo{db}
+}
+
+const listColumns = "l.id, l.owner_id, l.name, l.description, l.private, l.member_count, l.subscriber_count, l.createAt"
+
+// Create - store a list
+func (repo ListRepo) Create(ctx context.Context, list models.List) (uint64, error) {
+	statement, err := repo.db.PrepareContext(ctx,
+		"INSERT INTO lists (owner_id, name, description, private) VALUES (?, ?, ?, ?)",
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, list.OwnerID, list.Name, list.Description, list.Private)
+	if err != nil {
+		return 0, err
+	}
+	ID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(ID), nil
+}
+
+// FindById - a list, ID is 0 when it doesn't exist
+func (repo ListRepo) FindById(ctx context.Context, ID uint64) (models.List, error) {
+	lists, err := repo.lists(ctx, "SELECT "+listColumns+" FROM lists l WHERE l.id = ?", ID)
+	if err != nil || len(lists) == 0 {
+		return models.List{}, err
+	}
+	return lists[0], nil
+}
+
+// FindByOwner - lists created by an user, private ones only when asked
+func (repo ListRepo) FindByOwner(ctx context.Context, ownerID uint64, withPrivate bool) ([]models.List, error) {
+	return repo.lists(ctx,
+		"SELECT "+listColumns+" FROM lists l WHERE l.owner_id = ? AND (? OR NOT l.private) ORDER BY l.id",
+		ownerID, withPrivate,
+	)
+}
+
+// FindSubscribed - lists an user subscribed to
+func (repo ListRepo) FindSubscribed(ctx context.Context, userID uint64) ([]models.List, error) {
+	return repo.lists(ctx, `
+	   SELECT `+listColumns+`
+	   FROM lists l INNER JOIN list_subscriptions s ON (s.list_id = l.id)
+	   WHERE s.user_id = ? ORDER BY s.createAt DESC
+	`, userID)
+}
+
+// FindMemberships - lists an user was added to, leaving out private lists
+// of other owners, whose existence is theirs to share
+func (repo ListRepo) FindMemberships(ctx context.Context, userID uint64) ([]models.List, error) {
+	return repo.lists(ctx, `
+	   SELECT `+listColumns+`
+	   FROM lists l INNER JOIN list_members m ON (m.list_id = l.id)
+	   WHERE m.user_id = ? AND (NOT l.private OR l.owner_id = m.user_id) ORDER BY m.createAt
+	`, userID)
+}
+
+func (repo ListRepo) lists(ctx context.Context, query string, args ...interface{}) ([]models.List, error) {
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []models.List{}
+	for rows.Next() {
+		var list models.List
+		if err = rows.Scan(
+			&list.ID,
+			&list.OwnerID,
+			&list.Name,
+			&list.Description,
+			&list.Private,
+			&list.MemberCount,
+			&list.SubscriberCount,
+			&list.CreateAt,
+		); err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+	return lists, rows.Err()
+}
+
+// Update - change a list's name, description and visibility. Making a list
+// private drops the subscriptions of everyone but its owner.
+func (repo ListRepo) Update(ctx context.Context, ID uint64, list models.List) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx,
+		"UPDATE lists SET name = ?, description = ?, private = ? WHERE id = ?",
+		list.Name, list.Description, list.Private, ID,
+	); err != nil {
+		return err
+	}
+	if list.Private {
+		if _, err = tx.ExecContext(ctx, `
+		   DELETE s FROM list_subscriptions s INNER JOIN lists l ON (l.id = s.list_id)
+		   WHERE s.list_id = ? AND s.user_id <> l.owner_id
+		`, ID); err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx,
+			"UPDATE lists l SET subscriber_count = (SELECT count(*) FROM list_subscriptions s WHERE s.list_id = l.id) WHERE id = ?",
+			ID,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Delete - remove a list, cascading to its members and subscriptions
+func (repo ListRepo) Delete(ctx context.Context, ID uint64) error {
+	_, err := repo.db.ExecContext(ctx, "DELETE FROM lists WHERE id = ?", ID)
+	return err
+}
+
+// AddMember - add an active user to a list, false when there's no such user
+func (repo ListRepo) AddMember(ctx context.Context, ID uint64, userID uint64) (bool, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var active int
+	if err = tx.QueryRowContext(ctx,
+		"SELECT count(*) FROM users WHERE id = ? AND deactivatedAt IS NULL", userID,
+	).Scan(&active); err != nil || active == 0 {
+		return false, err
+	}
+	result, err := tx.ExecContext(ctx,
+		"INSERT IGNORE INTO list_members (list_id, user_id) VALUES (?, ?)", ID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	if err = updateListCount(ctx, tx, result, "member_count", ID, 1); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// RemoveMember - remove an user from a list, member_count only changes
+// for active users
+func (repo ListRepo) RemoveMember(ctx context.Context, ID uint64, userID uint64) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var active int
+	if err = tx.QueryRowContext(ctx,
+		"SELECT count(*) FROM users WHERE id = ? AND deactivatedAt IS NULL", userID,
+	).Scan(&active); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx,
+		"DELETE FROM list_members WHERE list_id = ? AND user_id = ?", ID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	// deactivating the user already took it out of member_count
+	if active > 0 {
+		if err = updateListCount(ctx, tx, result, "member_count", ID, -1); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Members - active users in a list
+func (repo ListRepo) Members(ctx context.Context, ID uint64) ([]models.User, error) {
+	rows, err := repo.db.QueryContext(ctx, `
+	   select u.id, u.name, u.nick, u.email, u.createAt, u.follower_count, u.following_count
+	   FROM users u INNER JOIN list_members m ON (m.user_id = u.id)
+	   WHERE m.list_id = ? AND u.deactivatedAt IS NULL
+	   ORDER BY m.createAt
+	`, ID)
+	if err != nil {
+		return []models.User{}, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(
+			&user.ID,
+			&user.Name,
+			&user.Nick,
+			&user.Email,
+			&user.CreateAt,
+			&user.FollowerCount,
+			&user.FollowingCount,
+		); err != nil {
+			return []models.User{}, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// Subscribe - subscribe an user to a list
+func (repo ListRepo) Subscribe(ctx context.Context, ID uint64, userID uint64) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"INSERT IGNORE INTO list_subscriptions (list_id, user_id) VALUES (?, ?)", ID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if err = updateListCount(ctx, tx, result, "subscriber_count", ID, 1); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Unsubscribe - remove an user's subscription to a list
+func (repo ListRepo) Unsubscribe(ctx context.Context, ID uint64, userID uint64) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"DELETE FROM list_subscriptions WHERE list_id = ? AND user_id = ?", ID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if err = updateListCount(ctx, tx, result, "subscriber_count", ID, -1); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// updateListCount - apply delta to a list counter only when the statement
+// changed a row, INSERT IGNORE and DELETE can be no-ops
+func updateListCount(ctx context.Context, tx *sql.Tx, result sql.Result, column string, ID uint64, delta int) error {
+	changed, err := result.RowsAffected()
+	if err != nil || changed == 0 {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE lists SET "+column+" = "+column+" + ? WHERE id = ?", delta, ID)
+	return err
+}
+
+// ReconcileCounts - recount active members and subscribers, returns how
+// many lists had drifted
+func (repo ListRepo) ReconcileCounts(ctx context.Context) (int64, error) {
+	result, err := repo.db.ExecContext(ctx, `
+	   UPDATE lists l
+	   INNER JOIN (
+	      SELECT c.id,
+	         (SELECT count(*) FROM list_members m INNER JOIN users u ON (u.id = m.user_id)
+	          WHERE m.list_id = c.id AND u.deactivatedAt IS NULL) AS members,
+	         (SELECT count(*) FROM list_subscriptions s WHERE s.list_id = c.id) AS subscribers
+	      FROM lists c
+	   ) counts ON (counts.id = l.id)
+	   SET l.member_count = counts.members, l.subscriber_count = counts.subscribers
+	   WHERE l.member_count <> counts.members OR l.subscriber_count <> counts.subscribers
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestListCounters(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	listRepo, userRepo := NewListRepo(db), NewUserRepo(db)
+	IDs := createUsers(t, db, 4)
+	owner, member, other, subscriber := IDs[0], IDs[1], IDs[2], IDs[3]
+	listID, err := listRepo.Create(ctx, models.List{OwnerID: owner, Name: "friends"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addMember := func(userID uint64, wantAdded bool) func() error {
+		return func() error {
+			added, err := listRepo.AddMember(ctx, listID, userID)
+			if err == nil && added != wantAdded {
+				err = fmt.Errorf("AddMember = %v, want %v", added, wantAdded)
+			}
+			return err
+		}
+	}
+	removeMember := func(userID uint64) func() error {
+		return func() error { return listRepo.RemoveMember(ctx, listID, userID) }
+	}
+	subscribe := func(userID uint64) func() error {
+		return func() error { return listRepo.Subscribe(ctx, listID, userID) }
+	}
+	unsubscribe := func(userID uint64) func() error {
+		return func() error { return listRepo.Unsubscribe(ctx, listID, userID) }
+	}
+
+	tests := []struct {
+		name  string
+		apply func() error
+		// member and subscriber counts
+		want [2]int
+	}{
+		{"add member", addMember(member, true), [2]int{1, 0}},
+		{"add member again", addMember(member, true), [2]int{1, 0}},
+		{"add another member", addMember(other, true), [2]int{2, 0}},
+		{"deactivate member", func() error { return userRepo.Delete(ctx, other) }, [2]int{1, 0}},
+		{"add deactivated user", addMember(other, false), [2]int{1, 0}},
+		{"remove deactivated member", removeMember(other), [2]int{1, 0}},
+		{"restore removed member", func() error { return userRepo.Restore(ctx, other) }, [2]int{1, 0}},
+		{"subscribe", subscribe(subscriber), [2]int{1, 1}},
+		{"subscribe again", subscribe(subscriber), [2]int{1, 1}},
+		{"owner subscribes", subscribe(owner), [2]int{1, 2}},
+		{"make private", func() error {
+			return listRepo.Update(ctx, listID, models.List{Name: "friends", Private: true})
+		}, [2]int{1, 1}},
+		{"owner unsubscribes", unsubscribe(owner), [2]int{1, 0}},
+		{"unsubscribe again", unsubscribe(owner), [2]int{1, 0}},
+		{"remove member", removeMember(member), [2]int{0, 0}},
+		{"remove member again", removeMember(member), [2]int{0, 0}},
+	}
+	for _, test := range tests {
+		if err := test.apply(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got := listCounts(t, db, listID); got != test.want {
+			t.Errorf("%s: members/subscribers %v, want %v", test.name, got, test.want)
+		}
+		drifted, err := listRepo.ReconcileCounts(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if drifted != 0 {
+			t.Errorf("%s: %d lists drifted from a recount", test.name, drifted)
+		}
+	}
+}
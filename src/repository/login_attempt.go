@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// LoginAttemptRepo - SQL storage for the login throttler, shared by every
+// API instance
+type LoginAttemptRepo struct {
+	db *sql.DB
+}
+
+// NewLoginAttemptRepo - create a new login attempt's repository
+func NewLoginAttemptRepo(db *sql.DB) *LoginAttemptRepo {
+	return &LoginAttemptRepo{db}
+}
+
+// Get - find the attempts recorded for key; a missing row is a zero value
+func (repo LoginAttemptRepo) Get(ctx context.Context, key string) (models.LoginAttempt, error) {
+	attempt := models.LoginAttempt{Key: key}
+	var lastFailure, lockedUntil sql.NullTime
+	err := repo.db.QueryRowContext(ctx,
+		"SELECT failures, last_failure, locked_until FROM login_attempts WHERE attempt_key = ?",
+		key,
+	).Scan(&attempt.Failures, &lastFailure, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return attempt, nil
+	}
+	if err != nil {
+		return models.LoginAttempt{}, err
+	}
+	attempt.LastFailure = lastFailure.Time
+	attempt.LockedUntil = lockedUntil.Time
+	return attempt, nil
+}
+
+// Fail - count a failure for key in a single statement, the same rules as
+// throttle.Next. LAST_INSERT_ID(expr) hands back the new count without a
+// second read another failure could race.
+func (repo LoginAttemptRepo) Fail(ctx context.Context, key string, now time.Time, maxAttempts int, lockout time.Duration) (models.LoginAttempt, error) {
+	lockedUntil := now.Add(lockout)
+	result, err := repo.db.ExecContext(ctx, `
+	   INSERT INTO login_attempts (attempt_key, failures, last_failure, locked_until)
+	   VALUES (?, LAST_INSERT_ID(1), ?, IF(? > 0 AND 1 >= ?, ?, NULL))
+	   ON DUPLICATE KEY UPDATE
+	   failures = LAST_INSERT_ID(IF(
+	      IF(locked_until IS NOT NULL, locked_until < ?, last_failure < ?), 1, failures + 1
+	   )),
+	   locked_until = IF(? > 0 AND failures >= ?, ?, NULL),
+	   last_failure = ?
+	`,
+		key, now, maxAttempts, maxAttempts, lockedUntil,
+		now, now.Add(-lockout),
+		maxAttempts, maxAttempts, lockedUntil,
+		now,
+	)
+	if err != nil {
+		return models.LoginAttempt{}, err
+	}
+	failures, err := result.LastInsertId()
+	if err != nil {
+		return models.LoginAttempt{}, err
+	}
+	attempt := models.LoginAttempt{Key: key, Failures: int(failures), LastFailure: now}
+	if maxAttempts > 0 && attempt.Failures >= maxAttempts {
+		attempt.LockedUntil = lockedUntil
+	}
+	return attempt, nil
+}
+
+// Refund - take back one failure for key. MySQL assigns left to right, so
+// locked_until sees the lowered count.
+func (repo LoginAttemptRepo) Refund(ctx context.Context, key string, maxAttempts int) error {
+	_, err := repo.db.ExecContext(ctx, `
+	   UPDATE login_attempts SET
+	   failures = IF(failures > 0, failures - 1, 0),
+	   locked_until = IF(? > 0 AND failures >= ?, locked_until, NULL)
+	   WHERE attempt_key = ?
+	`, maxAttempts, maxAttempts, key)
+	return err
+}
+
+// DeleteExpired - remove the attempts that no longer count at now, the
+// same rule as throttle.Expired, returns how many were removed
+func (repo LoginAttemptRepo) DeleteExpired(ctx context.Context, now time.Time, lockout time.Duration) (int64, error) {
+	result, err := repo.db.ExecContext(ctx, `
+	   DELETE FROM login_attempts
+	   WHERE IF(locked_until IS NOT NULL, locked_until < ?, last_failure IS NULL OR last_failure < ?)
+	`, now, now.Add(-lockout))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Delete - forget the attempts for key
+func (repo LoginAttemptRepo) Delete(ctx context.Context, key string) error {
+	statement, err := repo.db.PrepareContext(ctx, "DELETE FROM login_attempts WHERE attempt_key = ?")
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.ExecContext(ctx, key)
+	return err
+}
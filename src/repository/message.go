@@ -0,0 +1,390 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// MessageRepo struct to create a repository
+type MessageRepo struct {
+	db *sql.DB
+}
+
+// NewMessageRepo - create a new direct message's repository
+func NewMessageRepo(db *sql.DB) *MessageRepo {
+	return &MessageRepo{db}
+}
+
+// FindOneToOne - the conversation held only by these two users, 0 if none
+func (repo MessageRepo) FindOneToOne(ctx context.Context, userID uint64, otherID uint64) (uint64, error) {
+	var id uint64
+	err := repo.db.QueryRowContext(ctx, `
+	   SELECT m.conversation_id FROM conversation_members m
+	   WHERE m.user_id IN (?, ?)
+	   GROUP BY m.conversation_id
+	   HAVING count(*) = 2 AND (
+	      SELECT count(*) FROM conversation_members a WHERE a.conversation_id = m.conversation_id
+	   ) = 2
+	   LIMIT 1
+	`, userID, otherID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// CreateConversation - create a conversation with its members
+func (repo MessageRepo) CreateConversation(ctx context.Context, memberIDs []uint64) (uint64, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "INSERT INTO conversations () VALUES ()")
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	statement, err := tx.PrepareContext(ctx,
+		"INSERT INTO conversation_members (conversation_id, user_id) VALUES (?, ?)",
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer statement.Close()
+
+	for _, memberID := range memberIDs {
+		if _, err = statement.ExecContext(ctx, id, memberID); err != nil {
+			return 0, err
+		}
+	}
+	return uint64(id), tx.Commit()
+}
+
+// Recipients - the active users among ids, and whether each accepts
+// messages from the sender
+func (repo MessageRepo) Recipients(ctx context.Context, senderID uint64, ids []uint64) (map[uint64]bool, error) {
+	if len(ids) == 0 {
+		return map[uint64]bool{}, nil
+	}
+	args := []interface{}{senderID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := repo.db.QueryContext(ctx, `
+	   SELECT u.id, NOT u.dmFollowingOnly OR EXISTS (
+	      SELECT 1 FROM followers f WHERE f.user_id = ? AND f.follower_id = u.id
+	   )
+	   FROM users u
+	   WHERE u.id IN (?`+strings.Repeat(", ?", len(ids)-1)+`) AND u.deactivatedAt IS NULL
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accepts := map[uint64]bool{}
+	for rows.Next() {
+		var id uint64
+		var ok bool
+		if err = rows.Scan(&id, &ok); err != nil {
+			return nil, err
+		}
+		accepts[id] = ok
+	}
+	return accepts, rows.Err()
+}
+
+// Members - participants of a conversation with their read receipts
+func (repo MessageRepo) Members(ctx context.Context, conversationID uint64) ([]models.ConversationMember, error) {
+	rows, err := repo.db.QueryContext(ctx, `
+	   SELECT m.user_id, u.nick, m.last_read_id
+	   FROM conversation_members m INNER JOIN users u ON (u.id = m.user_id)
+	   WHERE m.conversation_id = ? ORDER BY m.user_id
+	`, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.ConversationMember{}
+	for rows.Next() {
+		var member models.ConversationMember
+		if err = rows.Scan(&member.UserID, &member.Nick, &member.LastReadID); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// IsMember - whether an user takes part in a conversation
+func (repo MessageRepo) IsMember(ctx context.Context, conversationID uint64, userID uint64) (bool, error) {
+	var count int
+	err := repo.db.QueryRowContext(ctx,
+		"SELECT count(*) FROM conversation_members WHERE conversation_id = ? AND user_id = ?",
+		conversationID, userID,
+	).Scan(&count)
+	return count > 0, err
+}
+
+// conversationQuery - conversations as a member sees them, with the count
+// of messages from others past the member's read receipt and the last
+// message the member didn't delete
+const conversationQuery = `
+   SELECT c.id, c.createAt, c.updatedAt, (
+      SELECT count(*) FROM messages msg
+      WHERE msg.conversation_id = c.id AND msg.id > m.last_read_id AND msg.sender_id <> m.user_id
+      AND NOT EXISTS (SELECT 1 FROM message_deletions d WHERE d.message_id = msg.id AND d.user_id = m.user_id)
+   ), lm.id, lm.sender_id, lm.body, lm.createAt
+   FROM conversations c INNER JOIN conversation_members m ON (m.conversation_id = c.id)
+   LEFT JOIN messages lm ON (lm.id = (
+      SELECT max(msg.id) FROM messages msg
+      WHERE msg.conversation_id = c.id
+      AND NOT EXISTS (SELECT 1 FROM message_deletions d WHERE d.message_id = msg.id AND d.user_id = m.user_id)
+   ))
+`
+
+// FindConversations - a page of an user's conversations, most recently
+// active first, with the last message and unread count as the user sees them
+func (repo MessageRepo) FindConversations(ctx context.Context, userID uint64, page int, limit int) ([]models.Conversation, error) {
+	return repo.conversations(ctx,
+		conversationQuery+"WHERE m.user_id = ? ORDER BY c.updatedAt DESC, c.id DESC LIMIT ? OFFSET ?",
+		userID, limit, page*limit,
+	)
+}
+
+// FindAllConversations - every conversation of an user, oldest first
+func (repo MessageRepo) FindAllConversations(ctx context.Context, userID uint64) ([]models.Conversation, error) {
+	return repo.conversations(ctx, conversationQuery+"WHERE m.user_id = ? ORDER BY c.id", userID)
+}
+
+// FindConversation - one conversation as the user sees it, ID is 0 when
+// the user isn't a member
+func (repo MessageRepo) FindConversation(ctx context.Context, conversationID uint64, userID uint64) (models.Conversation, error) {
+	conversations, err := repo.conversations(ctx,
+		conversationQuery+"WHERE c.id = ? AND m.user_id = ?",
+		conversationID, userID,
+	)
+	if err != nil || len(conversations) == 0 {
+		return models.Conversation{}, err
+	}
+	return conversations[0], nil
+}
+
+func (repo MessageRepo) conversations(ctx context.Context, query string, args ...interface{}) ([]models.Conversation, error) {
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []models.Conversation{}
+	for rows.Next() {
+		var conversation models.Conversation
+		var lastID, lastSenderID sql.NullInt64
+		var lastBody sql.NullString
+		var lastCreateAt sql.NullTime
+		if err = rows.Scan(
+			&conversation.ID,
+			&conversation.CreateAt,
+			&conversation.UpdatedAt,
+			&conversation.Unread,
+			&lastID,
+			&lastSenderID,
+			&lastBody,
+			&lastCreateAt,
+		); err != nil {
+			return nil, err
+		}
+		if lastID.Valid {
+			conversation.LastMessage = &models.Message{
+				ID:             uint64(lastID.Int64),
+				ConversationID: conversation.ID,
+				SenderID:       uint64(lastSenderID.Int64),
+				Body:           lastBody.String,
+				CreateAt:       lastCreateAt.Time,
+			}
+		}
+		conversations = append(conversations, conversation)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(conversations) == 0 {
+		return conversations, nil
+	}
+	ids := make([]interface{}, len(conversations))
+	for i, conversation := range conversations {
+		ids[i] = conversation.ID
+	}
+	members, err := repo.membersOf(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range conversations {
+		conversations[i].Members = members[conversations[i].ID]
+	}
+	return conversations, nil
+}
+
+// membersOf - participants of several conversations in one query
+func (repo MessageRepo) membersOf(ctx context.Context, conversationIDs []interface{}) (map[uint64][]models.ConversationMember, error) {
+	rows, err := repo.db.QueryContext(ctx, `
+	   SELECT m.conversation_id, m.user_id, u.nick, m.last_read_id
+	   FROM conversation_members m INNER JOIN users u ON (u.id = m.user_id)
+	   WHERE m.conversation_id IN (?`+strings.Repeat(", ?", len(conversationIDs)-1)+`)
+	   ORDER BY m.conversation_id, m.user_id
+	`, conversationIDs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := map[uint64][]models.ConversationMember{}
+	for rows.Next() {
+		var conversationID uint64
+		var member models.ConversationMember
+		if err = rows.Scan(&conversationID, &member.UserID, &member.Nick, &member.LastReadID); err != nil {
+			return nil, err
+		}
+		members[conversationID] = append(members[conversationID], member)
+	}
+	return members, rows.Err()
+}
+
+// CreateMessage - store a message, bump its conversation and mark it read
+// for the sender
+func (repo MessageRepo) CreateMessage(ctx context.Context, message models.Message) (uint64, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"INSERT INTO messages (conversation_id, sender_id, body) VALUES (?, ?, ?)",
+		message.ConversationID, message.SenderID, message.Body,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if _, err = tx.ExecContext(ctx,
+		"UPDATE conversations SET updatedAt = ? WHERE id = ?",
+		time.Now(), message.ConversationID,
+	); err != nil {
+		return 0, err
+	}
+	if _, err = tx.ExecContext(ctx,
+		"UPDATE conversation_members SET last_read_id = ? WHERE conversation_id = ? AND user_id = ?",
+		id, message.ConversationID, message.SenderID,
+	); err != nil {
+		return 0, err
+	}
+	return uint64(id), tx.Commit()
+}
+
+// FindMessages - a page of a conversation's history, newest first, without
+// the messages the user deleted for themselves
+func (repo MessageRepo) FindMessages(ctx context.Context, conversationID uint64, userID uint64, page int, limit int) ([]models.Message, error) {
+	rows, err := repo.db.QueryContext(ctx, `
+	   SELECT msg.id, msg.conversation_id, msg.sender_id, msg.body, msg.createAt
+	   FROM messages msg
+	   WHERE msg.conversation_id = ? AND NOT EXISTS (
+	      SELECT 1 FROM message_deletions d WHERE d.message_id = msg.id AND d.user_id = ?
+	   )
+	   ORDER BY msg.id DESC LIMIT ? OFFSET ?
+	`, conversationID, userID, limit, page*limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		var message models.Message
+		if err = rows.Scan(
+			&message.ID,
+			&message.ConversationID,
+			&message.SenderID,
+			&message.Body,
+			&message.CreateAt,
+		); err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+// FindAllMessages - every message of the conversations an user takes
+// part in, oldest first
+func (repo MessageRepo) FindAllMessages(ctx context.Context, userID uint64) ([]models.Message, error) {
+	rows, err := repo.db.QueryContext(ctx, `
+	   SELECT msg.id, msg.conversation_id, msg.sender_id, msg.body, msg.createAt
+	   FROM messages msg INNER JOIN conversation_members m ON (m.conversation_id = msg.conversation_id)
+	   WHERE m.user_id = ? ORDER BY msg.id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		var message models.Message
+		if err = rows.Scan(
+			&message.ID,
+			&message.ConversationID,
+			&message.SenderID,
+			&message.Body,
+			&message.CreateAt,
+		); err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, rows.Err()
+}
+
+// MarkRead - move an user's read receipt up to messageID, or to the latest
+// message when messageID is 0. Receipts never move back.
+func (repo MessageRepo) MarkRead(ctx context.Context, conversationID uint64, userID uint64, messageID uint64) error {
+	_, err := repo.db.ExecContext(ctx, `
+	   UPDATE conversation_members SET last_read_id = GREATEST(last_read_id, (
+	      SELECT coalesce(max(id), 0) FROM messages WHERE conversation_id = ? AND (? = 0 OR id <= ?)
+	   ))
+	   WHERE conversation_id = ? AND user_id = ?
+	`, conversationID, messageID, messageID, conversationID, userID)
+	return err
+}
+
+// DeleteForSelf - hide a message from one member, the others still see it.
+// Returns false when the message isn't in the conversation.
+func (repo MessageRepo) DeleteForSelf(ctx context.Context, conversationID uint64, userID uint64, messageID uint64) (bool, error) {
+	var count int
+	if err := repo.db.QueryRowContext(ctx,
+		"SELECT count(*) FROM messages WHERE id = ? AND conversation_id = ?",
+		messageID, conversationID,
+	).Scan(&count); err != nil || count == 0 {
+		return false, err
+	}
+	_, err := repo.db.ExecContext(ctx,
+		"INSERT IGNORE INTO message_deletions (message_id, user_id) VALUES (?, ?)",
+		messageID, userID,
+	)
+	return err == nil, err
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"testing"
+)
+
+func TestRecipients(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	userRepo := NewUserRepo(db)
+	IDs := createUsers(t, db, 5)
+	sender, open, following, notFollowing, deleted := IDs[0], IDs[1], IDs[2], IDs[3], IDs[4]
+	// the sender following notFollowing back doesn't let it through
+	follow(t, db, [2]uint64{following, sender}, [2]uint64{sender, notFollowing})
+	for _, ID := range []uint64{following, notFollowing} {
+		if err := userRepo.UpdateMessagingSettings(ctx, ID, models.MessagingSettings{FollowingOnly: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := userRepo.Delete(ctx, deleted); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		recipient uint64
+		wantFound bool
+		wantOK    bool
+	}{
+		{"accepts everyone", open, true, true},
+		{"following only, follows the sender", following, true, true},
+		{"following only, doesn't follow the sender", notFollowing, true, false},
+		{"deleted", deleted, false, false},
+		{"missing", deleted + 100, false, false},
+	}
+	var recipients []uint64
+	for _, test := range tests {
+		recipients = append(recipients, test.recipient)
+	}
+	accepts, err := NewMessageRepo(db).Recipients(ctx, sender, recipients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		ok, found := accepts[test.recipient]
+		if found != test.wantFound || ok != test.wantOK {
+			t.Errorf("%s: accepts %v, found %v, want %v, %v", test.name, ok, found, test.wantOK, test.wantFound)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// NotificationRepo struct to create a repository
+type NotificationRepo struct {
+	db *sql.DB
+}
+
+// NewNotificationRepo - create a new notification's repository
+func NewNotificationRepo(db *sql.DB) *NotificationRepo {
+	return &NotificationRepo{db}
+}
+
+// Create - store a notification
+func (repo NotificationRepo) Create(ctx context.Context, notification models.Notification) error {
+	statement, err := repo.db.PrepareContext(ctx,
+		"INSERT INTO notifications (user_id, kind, message, link) VALUES (?, ?, ?, ?)",
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.ExecContext(ctx, notification.UserID, notification.Kind, notification.Message, notification.Link)
+	return err
+}
+
+// FindByUser - a page of an user's notifications, newest first
+func (repo NotificationRepo) FindByUser(ctx context.Context, userID uint64, page int, limit int) ([]models.Notification, error) {
+	return repo.notifications(ctx, `
+	   SELECT id, user_id, kind, message, link, read_at, createAt FROM notifications
+	   WHERE user_id = ? ORDER BY id DESC LIMIT ? OFFSET ?
+	`, userID, limit, page*limit)
+}
+
+// FindAllByUser - every notification of an user, oldest first
+func (repo NotificationRepo) FindAllByUser(ctx context.Context, userID uint64) ([]models.Notification, error) {
+	return repo.notifications(ctx, `
+	   SELECT id, user_id, kind, message, link, read_at, createAt FROM notifications
+	   WHERE user_id = ? ORDER BY id
+	`, userID)
+}
+
+func (repo NotificationRepo) notifications(ctx context.Context, query string, args ...interface{}) ([]models.Notification, error) {
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var notification models.Notification
+		var readAt sql.NullTime
+		if err = rows.Scan(
+			&notification.ID,
+			&notification.UserID,
+			&notification.Kind,
+			&notification.Message,
+			&notification.Link,
+			&readAt,
+			&notification.CreateAt,
+		); err != nil {
+			return nil, err
+		}
+		if readAt.Valid {
+			notification.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
+
+// MarkRead - mark every unread notification of an user as read
+func (repo NotificationRepo) MarkRead(ctx context.Context, userID uint64) error {
+	_, err := repo.db.ExecContext(ctx,
+		"UPDATE notifications SET read_at = ? WHERE user_id = ? AND read_at IS NULL",
+		time.Now(), userID,
+	)
+	return err
+}
@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// OAuthRepo - clients, authorization codes and tokens of the OAuth2 server
+type OAuthRepo struct {
+	db *sql.DB
+}
+
+// NewOAuthRepo - create a new oauth's repository
+func NewOAuthRepo(db *sql.DB) *OAuthRepo {
+	return &OAuthRepo{db}
+}
+
+// CreateClient - register a client, client.SecretHash must already be set
+func (repo OAuthRepo) CreateClient(ctx context.Context, client models.OAuthClient) error {
+	statement, err := repo.db.PrepareContext(ctx, `
+	   INSERT INTO oauth_clients (id, secret_hash, owner_id, name, redirect_uris, scopes, confidential)
+	   VALUES (?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.ExecContext(ctx,
+		client.ID,
+		client.SecretHash,
+		client.OwnerID,
+		client.Name,
+		strings.Join(client.RedirectURIs, " "),
+		strings.Join(client.Scopes, " "),
+		client.Confidential,
+	)
+	return err
+}
+
+// FindClient - client by id, ID is empty when there is none
+func (repo OAuthRepo) FindClient(ctx context.Context, ID string) (models.OAuthClient, error) {
+	rows, err := repo.db.QueryContext(ctx, `
+	   SELECT id, secret_hash, owner_id, name, redirect_uris, scopes, confidential, createAt
+	   FROM oauth_clients WHERE id = ?
+	`, ID)
+	if err != nil {
+		return models.OAuthClient{}, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return scanOAuthClient(rows)
+	}
+	return models.OAuthClient{}, rows.Err()
+}
+
+// FindClientsByOwner - clients registered by an user
+func (repo OAuthRepo) FindClientsByOwner(ctx context.Context, ownerID uint64) ([]models.OAuthClient, error) {
+	rows, err := repo.db.QueryContext(ctx, `
+	   SELECT id, secret_hash, owner_id, name, redirect_uris, scopes, confidential, createAt
+	   FROM oauth_clients WHERE owner_id = ? ORDER BY createAt
+	`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := []models.OAuthClient{}
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	return clients, rows.Err()
+}
+
+// DeleteClient - remove a client of ownerID with its codes and tokens
+func (repo OAuthRepo) DeleteClient(ctx context.Context, ownerID uint64, ID string) (bool, error) {
+	result, err := repo.db.ExecContext(ctx, "DELETE FROM oauth_clients WHERE id = ? AND owner_id = ?", ID, ownerID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CreateCode - store an authorization code
+func (repo OAuthRepo) CreateCode(ctx context.Context, code models.OAuthCode) error {
+	statement, err := repo.db.PrepareContext(ctx, `
+	   INSERT INTO oauth_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+	   VALUES (?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.ExecContext(ctx,
+		code.Hash,
+		code.ClientID,
+		code.UserID,
+		code.RedirectURI,
+		strings.Join(code.Scopes, " "),
+		code.CodeChallenge,
+		code.ExpiresAt,
+	)
+	return err
+}
+
+// TakeCode - find and delete a code so it can only be exchanged once.
+// Hash is empty when the code doesn't exist or was already used.
+func (repo OAuthRepo) TakeCode(ctx context.Context, hash string) (models.OAuthCode, error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.OAuthCode{}, err
+	}
+	defer tx.Rollback()
+
+	var code models.OAuthCode
+	var scopes string
+	err = tx.QueryRowContext(ctx, `
+	   SELECT code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at
+	   FROM oauth_codes WHERE code_hash = ? FOR UPDATE
+	`, hash).Scan(
+		&code.Hash,
+		&code.ClientID,
+		&code.UserID,
+		&code.RedirectURI,
+		&scopes,
+		&code.CodeChallenge,
+		&code.ExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return models.OAuthCode{}, nil
+	}
+	if err != nil {
+		return models.OAuthCode{}, err
+	}
+	code.Scopes = strings.Fields(scopes)
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM oauth_codes WHERE code_hash = ?", hash); err != nil {
+		return models.OAuthCode{}, err
+	}
+	return code, tx.Commit()
+}
+
+// CreateToken - store an access token
+func (repo OAuthRepo) CreateToken(ctx context.Context, token models.OAuthToken) error {
+	statement, err := repo.db.PrepareContext(ctx, `
+	   INSERT INTO oauth_tokens (token_hash, client_id, user_id, scopes, expires_at)
+	   VALUES (?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	var userID sql.NullInt64
+	if token.UserID != 0 {
+		userID = sql.NullInt64{Int64: int64(token.UserID), Valid: true}
+	}
+	_, err = statement.ExecContext(ctx,
+		token.Hash, token.ClientID, userID, strings.Join(token.Scopes, " "), token.ExpiresAt,
+	)
+	return err
+}
+
+// FindToken - token by hash, Hash is empty when there is none
+func (repo OAuthRepo) FindToken(ctx context.Context, hash string) (models.OAuthToken, error) {
+	var token models.OAuthToken
+	var userID sql.NullInt64
+	var scopes string
+	var revokedAt sql.NullTime
+	err := repo.db.QueryRowContext(ctx, `
+	   SELECT t.token_hash, t.client_id, t.user_id, t.scopes, t.expires_at, t.revoked_at
+	   FROM oauth_tokens t LEFT JOIN users u ON (u.id = t.user_id)
+	   WHERE t.token_hash = ? AND u.deactivatedAt IS NULL
+	`, hash).Scan(&token.Hash, &token.ClientID, &userID, &scopes, &token.ExpiresAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return models.OAuthToken{}, nil
+	}
+	if err != nil {
+		return models.OAuthToken{}, err
+	}
+	token.UserID = uint64(userID.Int64)
+	token.Scopes = strings.Fields(scopes)
+	token.Revoked = revokedAt.Valid
+	return token, nil
+}
+
+// RevokeToken - revoke a token issued to clientID
+func (repo OAuthRepo) RevokeToken(ctx context.Context, clientID string, hash string) error {
+	_, err := repo.db.ExecContext(ctx,
+		"UPDATE oauth_tokens SET revoked_at = ? WHERE token_hash = ? AND client_id = ? AND revoked_at IS NULL",
+		time.Now(), hash, clientID,
+	)
+	return err
+}
+
+// FindGrants - tokens issued to clients for an user, with the client's name
+func (repo OAuthRepo) FindGrants(ctx context.Context, userID uint64) ([]models.OAuthGrant, error) {
+	rows, err := repo.db.QueryContext(ctx, `
+	   SELECT t.client_id, c.name, t.scopes, t.expires_at, t.revoked_at, t.createAt
+	   FROM oauth_tokens t INNER JOIN oauth_clients c ON (c.id = t.client_id)
+	   WHERE t.user_id = ? ORDER BY t.createAt
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []models.OAuthGrant{}
+	for rows.Next() {
+		var grant models.OAuthGrant
+		var scopes string
+		var revokedAt sql.NullTime
+		if err = rows.Scan(
+			&grant.ClientID,
+			&grant.ClientName,
+			&scopes,
+			&grant.ExpiresAt,
+			&revokedAt,
+			&grant.CreateAt,
+		); err != nil {
+			return nil, err
+		}
+		grant.Scopes = strings.Fields(scopes)
+		if revokedAt.Valid {
+			grant.RevokedAt = &revokedAt.Time
+		}
+		grants = append(grants, grant)
+	}
+	return grants, rows.Err()
+}
+
+// DeleteExpired - remove codes and tokens that expired before the given
+// time, returns how many rows went
+func (repo OAuthRepo) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+	for _, query := range []string{
+		"DELETE FROM oauth_codes WHERE expires_at < ?",
+		"DELETE FROM oauth_tokens WHERE expires_at < ?",
+	} {
+		result, err := repo.db.ExecContext(ctx, query, before)
+		if err != nil {
+			return deleted, err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += rows
+	}
+	return deleted, nil
+}
+
+func scanOAuthClient(rows *sql.Rows) (models.OAuthClient, error) {
+	var client models.OAuthClient
+	var redirectURIs, scopes string
+	if err := rows.Scan(
+		&client.ID,
+		&client.SecretHash,
+		&client.OwnerID,
+		&client.Name,
+		&redirectURIs,
+		&scopes,
+		&client.Confidential,
+		&client.CreateAt,
+	); err != nil {
+		return models.OAuthClient{}, err
+	}
+	client.RedirectURIs = strings.Fields(redirectURIs)
+	client.Scopes = strings.Fields(scopes)
+	return client, nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// RateLimitRepo - SQL storage for rate limit counters, shared by every
+// API instance
+type RateLimitRepo struct {
+	db *sql.DB
+}
+
+// NewRateLimitRepo - create a new rate limit's repository
+func NewRateLimitRepo(db *sql.DB) *RateLimitRepo {
+	return &RateLimitRepo{db}
+}
+
+// Hit - count one request for key in the window starting at start
+func (repo RateLimitRepo) Hit(ctx context.Context, key string, start time.Time, window time.Duration) (int, int, error) {
+	statement, err := repo.db.PrepareContext(ctx, `
+	   INSERT INTO rate_limits (limit_key, window_start, hits) VALUES (?, ?, 1)
+	   ON DUPLICATE KEY UPDATE hits = hits + 1
+	`)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer statement.Close()
+
+	if _, err = statement.ExecContext(ctx, key, start); err != nil {
+		return 0, 0, err
+	}
+
+	previousStart := start.Add(-window)
+	rows, err := repo.db.QueryContext(ctx,
+		"SELECT window_start, hits FROM rate_limits WHERE limit_key = ? AND window_start >= ?",
+		key, previousStart,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	var current, previous int
+	for rows.Next() {
+		var windowStart time.Time
+		var hits int
+		if err = rows.Scan(&windowStart, &hits); err != nil {
+			return 0, 0, err
+		}
+		if windowStart.Equal(start) {
+			current = hits
+		} else if windowStart.Equal(previousStart) {
+			previous = hits
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	_, err = repo.db.ExecContext(ctx,
+		"DELETE FROM rate_limits WHERE limit_key = ? AND window_start < ?",
+		key, previousStart,
+	)
+	return current, previous, err
+}
+
+// DeleteExpired - remove the counters of windows started before before,
+// keys that are never hit again keep theirs otherwise
+func (repo RateLimitRepo) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result, err := repo.db.ExecContext(ctx, "DELETE FROM rate_limits WHERE window_start < ?", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
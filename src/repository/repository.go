@@ -0,0 +1,11 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+// nullTime - store the zero time as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
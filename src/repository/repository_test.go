@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// testDB - a fresh api_test database, loaded with sql/sql.sql, on the
+// MySQL server TEST_DATABASE_DSN points at, e.g.
+// "user:password@tcp(127.0.0.1:3306)/". Without one the test is skipped.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.DBName = ""
+	server, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	for _, statement := range []string{"DROP DATABASE IF EXISTS api_test", "CREATE DATABASE api_test"} {
+		if _, err = server.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg.DBName, cfg.ParseTime, cfg.Loc, cfg.MultiStatements = "api_test", true, time.Local, true
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	schema, err := os.ReadFile("../../sql/sql.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the schema creates and selects the api database itself
+	var statements []string
+	for _, line := range strings.Split(string(schema), "\n") {
+		if !strings.HasPrefix(line, "CREATE DATABASE") && !strings.HasPrefix(line, "USE ") {
+			statements = append(statements, line)
+		}
+	}
+	if _, err = db.Exec(strings.Join(statements, "\n")); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// createUsers - n active users named user0, user1..., returns their IDs
+func createUsers(t *testing.T, db *sql.DB, n int) []uint64 {
+	t.Helper()
+	userRepo := NewUserRepo(db)
+	IDs := make([]uint64, n)
+	for i := range IDs {
+		ID, err := userRepo.Create(context.Background(), models.User{
+			Name:     fmt.Sprintf("User %d", i),
+			Nick:     fmt.Sprintf("user%d", i),
+			Email:    fmt.Sprintf("user%d@example.com", i),
+			Password: "!",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		IDs[i] = ID
+	}
+	return IDs
+}
+
+// follow - make each pair's first user follow the second
+func follow(t *testing.T, db *sql.DB, pairs ...[2]uint64) {
+	t.Helper()
+	userRepo := NewUserRepo(db)
+	for _, pair := range pairs {
+		if _, err := userRepo.FollowUser(context.Background(), pair[0], pair[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// followCounts - the stored follower and following counters of an user
+func followCounts(t *testing.T, db *sql.DB, ID uint64) [2]int {
+	t.Helper()
+	var counts [2]int
+	if err := db.QueryRow(
+		"SELECT follower_count, following_count FROM users WHERE id = ?", ID,
+	).Scan(&counts[0], &counts[1]); err != nil {
+		t.Fatal(err)
+	}
+	return counts
+}
+
+// assertReconciled - the stored counters match a recount
+func assertReconciled(t *testing.T, db *sql.DB) {
+	t.Helper()
+	drifted, err := NewUserRepo(db).ReconcileFollowCounts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drifted != 0 {
+		t.Errorf("%d users' counters drifted from a recount", drifted)
+	}
+}
+
+// listCounts - the stored member and subscriber counters of a list
+func listCounts(t *testing.T, db *sql.DB, ID uint64) [2]int {
+	t.Helper()
+	var counts [2]int
+	if err := db.QueryRow(
+		"SELECT member_count, subscriber_count FROM lists WHERE id = ?", ID,
+	).Scan(&counts[0], &counts[1]); err != nil {
+		t.Fatal(err)
+	}
+	return counts
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// SessionRepo struct to create a repository
+type SessionRepo struct {
+	db *sql.DB
+}
+
+// NewSessionRepo - create a new session's repository
+func NewSessionRepo(db *sql.DB) *SessionRepo {
+	return &SessionRepo{db}
+}
+
+// Create - store a new session
+func (repo SessionRepo) Create(ctx context.Context, session models.Session) error {
+	statement, err := repo.db.PrepareContext(ctx,
+		"INSERT INTO sessions (id, user_id, user_agent, ip, last_seen_at) VALUES (?, ?, ?, ?, ?)",
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.ExecContext(ctx, session.ID, session.UserID, session.UserAgent, session.IP, time.Now())
+	return err
+}
+
+// Active - whether the session exists, was not revoked and its user
+// wasn't deleted
+func (repo SessionRepo) Active(ctx context.Context, ID string) (bool, error) {
+	var count int
+	err := repo.db.QueryRowContext(ctx,
+		`SELECT count(*) FROM sessions s INNER JOIN users u ON (u.id = s.user_id)
+		 WHERE s.id = ? AND s.revoked_at IS NULL AND u.deactivatedAt IS NULL`,
+		ID,
+	).Scan(&count)
+	return count > 0, err
+}
+
+// Touch - record activity, at most once per minute per session
+func (repo SessionRepo) Touch(ctx context.Context, ID string, ip string, seenAt time.Time) error {
+	_, err := repo.db.ExecContext(ctx,
+		"UPDATE sessions SET last_seen_at = ?, ip = ? WHERE id = ? AND last_seen_at < ?",
+		seenAt, ip, ID, seenAt.Add(-time.Minute),
+	)
+	return err
+}
+
+// FindByUser - sessions of an user created after since and not revoked
+func (repo SessionRepo) FindByUser(ctx context.Context, userID uint64, since time.Time) ([]models.Session, error) {
+	rows, err := repo.db.QueryContext(ctx, `
+	   SELECT id, user_id, user_agent, ip, createAt, last_seen_at FROM sessions
+	   WHERE user_id = ? AND revoked_at IS NULL AND createAt > ?
+	   ORDER BY last_seen_at DESC
+	`, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		if err = rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.UserAgent,
+			&session.IP,
+			&session.CreateAt,
+			&session.LastSeenAt,
+		); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// Revoke - revoke a session of userID, false when there is no such session
+func (repo SessionRepo) Revoke(ctx context.Context, userID uint64, ID string) (bool, error) {
+	statement, err := repo.db.PrepareContext(ctx,
+		"UPDATE sessions SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+	)
+	if err != nil {
+		return false, err
+	}
+	defer statement.Close()
+
+	result, err := statement.ExecContext(ctx, time.Now(), ID, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RevokeAll - revoke every session of userID
+func (repo SessionRepo) RevokeAll(ctx context.Context, userID uint64) error {
+	statement, err := repo.db.PrepareContext(ctx,
+		"UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL",
+	)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	_, err = statement.ExecContext(ctx, time.Now(), userID)
+	return err
+}
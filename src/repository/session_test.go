@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestSessionRevocation(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo, userRepo := NewSessionRepo(db), NewUserRepo(db)
+	IDs := createUsers(t, db, 3)
+	owner, other, deleted := IDs[0], IDs[1], IDs[2]
+	session := func(n int) string { return fmt.Sprintf("%032d", n) }
+	for n, userID := range []uint64{owner, owner, owner, other, deleted} {
+		if err := repo.Create(ctx, models.Session{ID: session(n), UserID: userID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := userRepo.Delete(ctx, deleted); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		apply      func() error
+		wantActive []bool
+	}{
+		{"created", nil, []bool{true, true, true, true, false}},
+		{"revoke another user's session", func() error {
+			revoked, err := repo.Revoke(ctx, other, session(0))
+			if err == nil && revoked {
+				err = fmt.Errorf("revoked another user's session")
+			}
+			return err
+		}, []bool{true, true, true, true, false}},
+		{"revoke", func() error {
+			_, err := repo.Revoke(ctx, owner, session(0))
+			return err
+		}, []bool{false, true, true, true, false}},
+		{"revoke again", func() error {
+			revoked, err := repo.Revoke(ctx, owner, session(0))
+			if err == nil && revoked {
+				err = fmt.Errorf("revoked a revoked session")
+			}
+			return err
+		}, []bool{false, true, true, true, false}},
+		{"revoke all", func() error { return repo.RevokeAll(ctx, owner) }, []bool{false, false, false, true, false}},
+		{"reset credentials", func() error {
+			return userRepo.ResetCredentials(ctx, other, "!")
+		}, []bool{false, false, false, false, false}},
+		{"restore", func() error { return userRepo.Restore(ctx, deleted) }, []bool{false, false, false, false, true}},
+	}
+	for _, test := range tests {
+		if test.apply != nil {
+			if err := test.apply(); err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+		}
+		for n, want := range test.wantActive {
+			active, err := repo.Active(ctx, session(n))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if active != want {
+				t.Errorf("%s: session %d active %v, want %v", test.name, n, active, want)
+			}
+		}
+	}
+}
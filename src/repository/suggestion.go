@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// SuggestionRepo struct to create a repository
+type SuggestionRepo struct {
+	db *sql.DB
+}
+
+// NewSuggestionRepo - create a new follow suggestion's repository
+func NewSuggestionRepo(db *sql.DB) *SuggestionRepo {
+	return &SuggestionRepo{db}
+}
+
+// Compute - rank accounts the user doesn't follow yet from the follow graph.
+// Candidates are followed by someone the user follows (friends of friends)
+// or follow the user. Each mutual path counts 1, following the user back
+// counts 2 and having been seen since activeSince adds 0.5.
+func (repo SuggestionRepo) Compute(ctx context.Context, userID uint64, activeSince time.Time, limit int) ([]models.Suggestion, error) {
+	rows, err := repo.db.QueryContext(ctx, `
+	   SELECT id, name, nick, follower_count, following_count, mutuals, follows_you,
+	      mutuals + 2 * follows_you + 0.5 * active AS score
+	   FROM (
+	      SELECT c.id, c.name, c.nick, c.follower_count, c.following_count,
+	         (SELECT count(*) FROM followers f1 INNER JOIN followers f2 ON (f2.follower_id = f1.user_id)
+	          WHERE f1.follower_id = ? AND f2.user_id = c.id) AS mutuals,
+	         EXISTS (SELECT 1 FROM followers f WHERE f.user_id = ? AND f.follower_id = c.id) AS follows_you,
+	         EXISTS (SELECT 1 FROM sessions s WHERE s.user_id = c.id AND s.last_seen_at > ?) AS active
+	      FROM users c
+	      WHERE c.id IN (
+	         SELECT f2.user_id FROM followers f1 INNER JOIN followers f2 ON (f2.follower_id = f1.user_id)
+	         WHERE f1.follower_id = ?
+	         UNION
+	         SELECT follower_id FROM followers WHERE user_id = ?
+	      )
+	      AND c.id <> ? AND c.deactivatedAt IS NULL
+	      AND NOT EXISTS (SELECT 1 FROM followers a WHERE a.user_id = c.id AND a.follower_id = ?)
+	   ) candidates
+	   ORDER BY score DESC, id LIMIT ?
+	`, userID, userID, activeSince, userID, userID, userID, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	suggestions := []models.Suggestion{}
+	for rows.Next() {
+		suggestion := models.Suggestion{ComputedAt: now}
+		if err = rows.Scan(
+			&suggestion.User.ID,
+			&suggestion.User.Name,
+			&suggestion.User.Nick,
+			&suggestion.User.FollowerCount,
+			&suggestion.User.FollowingCount,
+			&suggestion.Mutuals,
+			&suggestion.FollowsYou,
+			&suggestion.Score,
+		); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, rows.Err()
+}
+
+// Store - replace the cached suggestions of an user and record when they
+// were computed, even when there are none
+func (repo SuggestionRepo) Store(ctx context.Context, userID uint64, computedAt time.Time, suggestions []models.Suggestion) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM follow_suggestions WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `
+	   INSERT INTO follow_suggestion_runs (user_id, computedAt) VALUES (?, ?)
+	   ON DUPLICATE KEY UPDATE computedAt = VALUES(computedAt)
+	`, userID, computedAt); err != nil {
+		return err
+	}
+	statement, err := tx.PrepareContext(ctx, `
+	   INSERT INTO follow_suggestions (user_id, candidate_id, score, mutuals, follows_you, computedAt)
+	   VALUES (?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	for _, suggestion := range suggestions {
+		if _, err = statement.ExecContext(ctx,
+			userID,
+			suggestion.User.ID,
+			suggestion.Score,
+			suggestion.Mutuals,
+			suggestion.FollowsYou,
+			suggestion.ComputedAt,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// FindCached - cached suggestions when they were computed after since,
+// leaving out accounts followed or deactivated since then. The bool is
+// false when there is no such cache, an empty cache is still a cache.
+func (repo SuggestionRepo) FindCached(ctx context.Context, userID uint64, since time.Time, limit int) ([]models.Suggestion, bool, error) {
+	var computedAt time.Time
+	err := repo.db.QueryRowContext(ctx,
+		"SELECT computedAt FROM follow_suggestion_runs WHERE user_id = ?", userID,
+	).Scan(&computedAt)
+	if err == sql.ErrNoRows || (err == nil && !computedAt.After(since)) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	rows, err := repo.db.QueryContext(ctx, `
+	   SELECT u.id, u.name, u.nick, u.follower_count, u.following_count,
+	      s.mutuals, s.follows_you, s.score, s.computedAt
+	   FROM follow_suggestions s INNER JOIN users u ON (u.id = s.candidate_id)
+	   WHERE s.user_id = ? AND u.deactivatedAt IS NULL
+	   AND NOT EXISTS (SELECT 1 FROM followers a WHERE a.user_id = u.id AND a.follower_id = s.user_id)
+	   ORDER BY s.score DESC, u.id LIMIT ?
+	`, userID, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	suggestions := []models.Suggestion{}
+	for rows.Next() {
+		var suggestion models.Suggestion
+		if err = rows.Scan(
+			&suggestion.User.ID,
+			&suggestion.User.Name,
+			&suggestion.User.Nick,
+			&suggestion.User.FollowerCount,
+			&suggestion.User.FollowingCount,
+			&suggestion.Mutuals,
+			&suggestion.FollowsYou,
+			&suggestion.Score,
+			&suggestion.ComputedAt,
+		); err != nil {
+			return nil, false, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, true, rows.Err()
+}
+
+// recentlySeen - condition on the user aliased u: one of its sessions was
+// used after the bound time
+const recentlySeen = "EXISTS (SELECT 1 FROM sessions s WHERE s.user_id = u.id AND s.last_seen_at > ?)"
+
+// Stale - users seen since activeSince whose suggestions were computed
+// before the given time, then those who never had any computed, so their
+// first request is served from the cache too
+func (repo SuggestionRepo) Stale(ctx context.Context, before time.Time, activeSince time.Time, limit int) ([]uint64, error) {
+	rows, err := repo.db.QueryContext(ctx, `
+	   (SELECT r.user_id FROM follow_suggestion_runs r
+	    INNER JOIN users u ON (u.id = r.user_id)
+	    WHERE r.computedAt < ? AND u.deactivatedAt IS NULL AND `+recentlySeen+`
+	    ORDER BY r.computedAt LIMIT ?)
+	   UNION ALL
+	   (SELECT u.id FROM users u
+	    WHERE u.deactivatedAt IS NULL
+	    AND NOT EXISTS (SELECT 1 FROM follow_suggestion_runs r WHERE r.user_id = u.id)
+	    AND `+recentlySeen+`
+	    LIMIT ?)
+	   LIMIT ?
+	`, before, activeSince, limit, activeSince, limit, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []uint64
+	for rows.Next() {
+		var userID uint64
+		if err = rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		users = append(users, userID)
+	}
+	return users, rows.Err()
+}
+
+// DeleteInactive - drop the cached suggestions of users not seen since
+// activeSince, they are computed again on their next request. Returns the
+// rows deleted from both tables.
+func (repo SuggestionRepo) DeleteInactive(ctx context.Context, activeSince time.Time) (int64, error) {
+	result, err := repo.db.ExecContext(ctx, `
+	   DELETE r, f FROM follow_suggestion_runs r
+	   INNER JOIN users u ON (u.id = r.user_id)
+	   LEFT JOIN follow_suggestions f ON (f.user_id = r.user_id)
+	   WHERE u.deactivatedAt IS NOT NULL OR NOT `+recentlySeen+`
+	`, activeSince)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// seen - give an user a session last used at the given time
+func seen(t *testing.T, db *sql.DB, userID uint64, at time.Time) {
+	t.Helper()
+	if _, err := db.Exec(
+		"INSERT INTO sessions (id, user_id, user_agent, ip, last_seen_at) VALUES (?, ?, '', '', ?)",
+		fmt.Sprintf("%032d", userID), userID, at,
+	); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func suggestedIDs(suggestions []models.Suggestion) []uint64 {
+	IDs := []uint64{}
+	for _, suggestion := range suggestions {
+		IDs = append(IDs, suggestion.User.ID)
+	}
+	return IDs
+}
+
+func TestSuggestionCache(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewSuggestionRepo(db)
+	IDs := createUsers(t, db, 4)
+	me, friend, friendOfFriend, fan := IDs[0], IDs[1], IDs[2], IDs[3]
+	follow(t, db, [2]uint64{me, friend}, [2]uint64{friend, friendOfFriend}, [2]uint64{fan, me})
+
+	now := time.Now().Truncate(time.Second)
+	computed, err := repo.Compute(ctx, me, now.Add(-time.Hour), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// following back outranks one mutual
+	if got, want := suggestedIDs(computed), []uint64{fan, friendOfFriend}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Compute = %v, want %v", got, want)
+	}
+	if err = repo.Store(ctx, me, now, computed); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		apply      func() error
+		since      time.Time
+		wantCached bool
+		wantIDs    []uint64
+	}{
+		{"fresh", nil, now.Add(-time.Minute), true, []uint64{fan, friendOfFriend}},
+		{"expired", nil, now, false, nil},
+		{"followed since", func() error {
+			_, err := NewUserRepo(db).FollowUser(ctx, me, fan)
+			return err
+		}, now.Add(-time.Minute), true, []uint64{friendOfFriend}},
+		{"deactivated since", func() error {
+			return NewUserRepo(db).Delete(ctx, friendOfFriend)
+		}, now.Add(-time.Minute), true, []uint64{}},
+	}
+	for _, test := range tests {
+		if test.apply != nil {
+			if err := test.apply(); err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+		}
+		suggestions, cached, err := repo.FindCached(ctx, me, test.since, 10)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if cached != test.wantCached {
+			t.Errorf("%s: cached %v, want %v", test.name, cached, test.wantCached)
+		}
+		if cached && !reflect.DeepEqual(suggestedIDs(suggestions), test.wantIDs) {
+			t.Errorf("%s: suggestions %v, want %v", test.name, suggestedIDs(suggestions), test.wantIDs)
+		}
+	}
+}
+
+func TestStaleAndDeleteInactive(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	repo := NewSuggestionRepo(db)
+	now := time.Now().Truncate(time.Second)
+	before, activeSince := now.Add(-time.Hour), now.Add(-7*24*time.Hour)
+
+	users := []struct {
+		name       string
+		computedAt time.Time
+		seenAt     time.Time
+		deleted    bool
+		wantStale  bool
+		wantKept   bool
+	}{
+		{"fresh", now, now, false, false, true},
+		{"expired", now.Add(-2 * time.Hour), now, false, true, true},
+		{"expired, inactive", now.Add(-2 * time.Hour), now.Add(-8 * 24 * time.Hour), false, false, false},
+		{"expired, deleted", now.Add(-2 * time.Hour), now, true, false, false},
+		{"never computed", time.Time{}, now, false, true, false},
+		{"never computed, never seen", time.Time{}, time.Time{}, false, false, false},
+	}
+	IDs := createUsers(t, db, len(users))
+	var wantStale, wantKept []uint64
+	for i, user := range users {
+		if !user.seenAt.IsZero() {
+			seen(t, db, IDs[i], user.seenAt)
+		}
+		if !user.computedAt.IsZero() {
+			// everyone is suggested the first user
+			suggestions := []models.Suggestion{{User: models.User{ID: IDs[0]}, ComputedAt: user.computedAt}}
+			if err := repo.Store(ctx, IDs[i], user.computedAt, suggestions); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if user.deleted {
+			if err := NewUserRepo(db).Delete(ctx, IDs[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if user.wantStale {
+			wantStale = append(wantStale, IDs[i])
+		}
+		if user.wantKept {
+			wantKept = append(wantKept, IDs[i])
+		}
+	}
+
+	stale, err := repo.Stale(ctx, before, activeSince, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stale, wantStale) {
+		t.Errorf("Stale = %v, want %v", stale, wantStale)
+	}
+	if stale, err = repo.Stale(ctx, before, activeSince, 1); err != nil || len(stale) != 1 {
+		t.Errorf("Stale with limit 1 = %v, %v, want one user", stale, err)
+	}
+
+	if _, err = repo.DeleteInactive(ctx, activeSince); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range []string{"follow_suggestion_runs", "follow_suggestions"} {
+		var kept []uint64
+		rows, err := db.Query("SELECT DISTINCT user_id FROM " + table + " ORDER BY user_id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var ID uint64
+			if err = rows.Scan(&ID); err != nil {
+				t.Fatal(err)
+			}
+			kept = append(kept, ID)
+		}
+		rows.Close()
+		if !reflect.DeepEqual(kept, wantKept) {
+			t.Errorf("%s kept %v after DeleteInactive, want %v", table, kept, wantKept)
+		}
+	}
+}
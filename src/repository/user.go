@@ -2,8 +2,11 @@ package repository
 
 import (
 	"api/src/models"
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 )
 
 // UserRepo struct to create a repository
@@ -16,8 +19,8 @@ func NewUserRepo(db *sql.DB) *UserRepo {
 	return &UserRepo{db}
 }
 
-func (userRepo UserRepo) Create(user models.User) (uint64, error) {
-	statement, error := userRepo.db.Prepare(
+func (userRepo UserRepo) Create(ctx context.Context, user models.User) (uint64, error) {
+	statement, error := userRepo.db.PrepareContext(ctx,
 		"insert into users (name, nick, email, password) values (?, ?, ?, ?)",
 	)
 	if error != nil {
@@ -25,7 +28,7 @@ func (userRepo UserRepo) Create(user models.User) (uint64, error) {
 	}
 	defer statement.Close()
 
-	result, error := statement.Exec(user.Name, user.Nick, user.Email, user.Password)
+	result, error := statement.ExecContext(ctx, user.Name, user.Nick, user.Email, user.Password)
 	if error != nil {
 		return 0, error
 	}
@@ -37,8 +40,10 @@ func (userRepo UserRepo) Create(user models.User) (uint64, error) {
 	return uint64(ID), nil
 }
 
-func (userRepo UserRepo) FindAll() ([]models.User, error) {
-	rows, error := userRepo.db.Query("select * from users")
+func (userRepo UserRepo) FindAll(ctx context.Context) ([]models.User, error) {
+	rows, error := userRepo.db.QueryContext(ctx,
+		"select id, name, nick, email, password, createAt from users WHERE deactivatedAt IS NULL",
+	)
 	if error != nil {
 		return []models.User{}, error
 	}
@@ -53,14 +58,14 @@ func (userRepo UserRepo) FindAll() ([]models.User, error) {
 		}
 		users = append(users, user)
 	}
-	return users, nil
+	return users, rows.Err()
 }
 
 // Find - find users by name or nick
-func (UserRepo UserRepo) Find(nameOrNick string) ([]models.User, error) {
+func (UserRepo UserRepo) Find(ctx context.Context, nameOrNick string) ([]models.User, error) {
 	nameOrNick = fmt.Sprintf("%%%s%%", nameOrNick) // %nameOrNick%
-	rows, error := UserRepo.db.Query(
-		"select id, name, nick, email, createAt from users WHERE name LIKE ? or nick LIKE ?",
+	rows, error := UserRepo.db.QueryContext(ctx,
+		"select id, name, nick, email, createAt, follower_count, following_count from users WHERE (name LIKE ? or nick LIKE ?) AND deactivatedAt IS NULL",
 		nameOrNick, nameOrNick,
 	)
 	if error != nil {
@@ -77,17 +82,19 @@ func (UserRepo UserRepo) Find(nameOrNick string) ([]models.User, error) {
 			&user.Nick,
 			&user.Email,
 			&user.CreateAt,
+			&user.FollowerCount,
+			&user.FollowingCount,
 		); error != nil {
 			return nil, error
 		}
 		users = append(users, user)
 	}
-	return users, nil
+	return users, rows.Err()
 }
 
-func (UserRepo UserRepo) FindById(ID uint64) (models.User, error) {
-	rows, err := UserRepo.db.Query(
-		"SELECT id, name, nick, email, createAt FROM users WHERE id = ? ",
+func (UserRepo UserRepo) FindById(ctx context.Context, ID uint64) (models.User, error) {
+	rows, err := UserRepo.db.QueryContext(ctx,
+		"SELECT id, name, nick, email, createAt, follower_count, following_count FROM users WHERE id = ? AND deactivatedAt IS NULL",
 		ID,
 	)
 	if err != nil {
@@ -104,6 +111,8 @@ func (UserRepo UserRepo) FindById(ID uint64) (models.User, error) {
 			&user.Nick,
 			&user.Email,
 			&user.CreateAt,
+			&user.FollowerCount,
+			&user.FollowingCount,
 		); err != nil {
 			return models.User{}, err
 		}
@@ -111,8 +120,8 @@ func (UserRepo UserRepo) FindById(ID uint64) (models.User, error) {
 	return user, nil
 }
 
-func (UserRepo UserRepo) Update(ID uint64, data models.User) error {
-	statement, err := UserRepo.db.Prepare(
+func (UserRepo UserRepo) Update(ctx context.Context, ID uint64, data models.User) error {
+	statement, err := UserRepo.db.PrepareContext(ctx,
 		"UPDATE users set name = ?, nick = ?, email = ? where id = ?",
 	)
 	if err != nil {
@@ -120,80 +129,321 @@ func (UserRepo UserRepo) Update(ID uint64, data models.User) error {
 	}
 	defer statement.Close()
 
-	if _, err = statement.Exec(data.Name, data.Nick, data.Email, ID); err != nil {
+	if _, err = statement.ExecContext(ctx, data.Name, data.Nick, data.Email, ID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (UserRepo UserRepo) Delete(ID uint64) error {
-	statement, err := UserRepo.db.Prepare("DELETE FROM users WHERE id = ?")
+// Delete - deactivate an user, the row is purged after the restore window
+func (UserRepo UserRepo) Delete(ctx context.Context, ID uint64) error {
+	return UserRepo.setDeactivated(ctx, ID,
+		"UPDATE users SET deactivatedAt = ? WHERE id = ? AND deactivatedAt IS NULL", time.Now(), -1,
+	)
+}
+
+// Restore - reactivate a deactivated user
+func (UserRepo UserRepo) Restore(ctx context.Context, ID uint64) error {
+	return UserRepo.setDeactivated(ctx, ID,
+		"UPDATE users SET deactivatedAt = ? WHERE id = ? AND deactivatedAt IS NOT NULL", nil, 1,
+	)
+}
+
+// ResetCredentials - replace the password hash and drop everything that
+// authenticates as the user: sessions, personal access tokens and tokens
+// issued to OAuth2 clients
+func (UserRepo UserRepo) ResetCredentials(ctx context.Context, ID uint64, passwordHash string) error {
+	tx, err := UserRepo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err = tx.ExecContext(ctx, "UPDATE users SET password = ? WHERE id = ?", passwordHash, ID); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx,
+		"UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now, ID,
+	); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM access_tokens WHERE user_id = ?", ID); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx,
+		"UPDATE oauth_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now, ID,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setDeactivated - run the deactivation or restore query and, when it
+// changed the user, apply delta to the counters that only count active
+// users
+func (UserRepo UserRepo) setDeactivated(ctx context.Context, ID uint64, query string, deactivatedAt interface{}, delta int) error {
+	tx, err := UserRepo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, deactivatedAt, ID)
+	if err != nil {
+		return err
+	}
+	changed, err := result.RowsAffected()
+	if err != nil || changed == 0 {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `
+	   UPDATE lists l INNER JOIN list_members m ON (m.list_id = l.id)
+	   SET l.member_count = l.member_count + ? WHERE m.user_id = ?
+	`, delta, ID); err != nil {
+		return err
+	}
+	// the accounts it follows and its followers stop or start counting it
+	if _, err = tx.ExecContext(ctx, `
+	   UPDATE users u INNER JOIN followers f ON (f.user_id = u.id)
+	   SET u.follower_count = u.follower_count + ? WHERE f.follower_id = ?
+	`, delta, ID); err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, `
+	   UPDATE users u INNER JOIN followers f ON (f.follower_id = u.id)
+	   SET u.following_count = u.following_count + ? WHERE f.user_id = ?
+	`, delta, ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Purge - remove users deactivated before the given time, cascading
+// to their follows, sessions and tokens. Outgoing follows are deleted
+// explicitly too, schemas created before followers.follower_id had its
+// foreign key don't cascade them.
+func (UserRepo UserRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	tx, err := UserRepo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, `
+	   DELETE f FROM followers f INNER JOIN users u ON (u.id = f.follower_id)
+	   WHERE u.deactivatedAt IS NOT NULL AND u.deactivatedAt < ?
+	`, before); err != nil {
+		return 0, err
+	}
+	result, err := tx.ExecContext(ctx,
+		"DELETE FROM users WHERE deactivatedAt IS NOT NULL AND deactivatedAt < ?",
+		before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return purged, tx.Commit()
+}
+
+// FindPassword - get the password hash of an user
+func (UserRepo UserRepo) FindPassword(ctx context.Context, ID uint64) (string, error) {
+	row, err := UserRepo.db.QueryContext(ctx, "select password from users where id = ?", ID)
+	if err != nil {
+		return "", err
+	}
+	defer row.Close()
+
+	var password string
+	if row.Next() {
+		if err = row.Scan(&password); err != nil {
+			return "", err
+		}
+	}
+	return password, nil
+}
+
+// UpdatePassword - replace the stored password hash
+func (UserRepo UserRepo) UpdatePassword(ctx context.Context, ID uint64, passwordHash string) error {
+	statement, err := UserRepo.db.PrepareContext(ctx, "UPDATE users SET password = ? WHERE id = ?")
 	if err != nil {
 		return err
 	}
 	defer statement.Close()
-	if _, err = statement.Exec(ID); err != nil {
+
+	if _, err = statement.ExecContext(ctx, passwordHash, ID); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (UserRepo UserRepo) FindByEmail(email string) (models.User, error) {
-	row, err := UserRepo.db.Query("select id, password from users where email = ?", email)
+func (UserRepo UserRepo) FindByEmail(ctx context.Context, email string) (models.User, error) {
+	row, err := UserRepo.db.QueryContext(ctx,
+		"select id, password, deactivatedAt from users where email = ?",
+		email,
+	)
 	if err != nil {
 		return models.User{}, err
 	}
 	defer row.Close()
 	var user models.User
 	if row.Next() {
-		if err = row.Scan(&user.ID, &user.Password); err != nil {
+		var deactivatedAt sql.NullTime
+		if err = row.Scan(&user.ID, &user.Password, &deactivatedAt); err != nil {
 			return models.User{}, err
 		}
+		if deactivatedAt.Valid {
+			user.DeactivatedAt = &deactivatedAt.Time
+		}
 	}
 	return user, nil
 }
 
-// FollowUser - create a new row in followers table
-func (UserRepo UserRepo) FollowUser(follower_id uint64, user_id uint64) error {
-	statement, err := UserRepo.db.Prepare(
-		"INSERT IGNORE INTO followers (user_id, follower_id) VALUES (?, ?)",
+// FindDeactivatedAt - when an user was deleted, nil while active
+func (UserRepo UserRepo) FindDeactivatedAt(ctx context.Context, ID uint64) (*time.Time, error) {
+	var deactivatedAt sql.NullTime
+	err := UserRepo.db.QueryRowContext(ctx,
+		"SELECT deactivatedAt FROM users WHERE id = ?", ID,
+	).Scan(&deactivatedAt)
+	if err != nil || !deactivatedAt.Valid {
+		return nil, err
+	}
+	return &deactivatedAt.Time, nil
+}
+
+// NickExists - whether a nick is already taken
+func (UserRepo UserRepo) NickExists(ctx context.Context, nick string) (bool, error) {
+	var count int
+	err := UserRepo.db.QueryRowContext(ctx, "SELECT count(*) FROM users WHERE nick = ?", nick).Scan(&count)
+	return count > 0, err
+}
+
+// FollowUser - create a new row in followers table, and bump both
+// users' counters when it didn't exist yet. False when there is no active
+// user to follow.
+func (UserRepo UserRepo) FollowUser(ctx context.Context, follower_id uint64, user_id uint64) (bool, error) {
+	tx, err := UserRepo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT IGNORE INTO followers (user_id, follower_id)
+		 SELECT id, ? FROM users WHERE id = ? AND deactivatedAt IS NULL`,
+		follower_id, user_id,
 	)
 	if err != nil {
-		return err
+		return false, err
 	}
-	defer statement.Close()
+	// nothing inserted: already followed, or nobody to follow
+	if inserted, err := result.RowsAffected(); err != nil || inserted == 0 {
+		if err != nil {
+			return false, err
+		}
+		var active bool
+		err = tx.QueryRowContext(ctx,
+			"SELECT EXISTS(SELECT 1 FROM users WHERE id = ? AND deactivatedAt IS NULL)", user_id,
+		).Scan(&active)
+		return active, err
+	}
+	if err = updateFollowCounts(ctx, tx, result, follower_id, user_id, 1); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
 
-	if _, err := statement.Exec(user_id, follower_id); err != nil {
+// UnFollowUser - remove a row in followers table, and drop both users'
+// counters when it existed
+func (UserRepo UserRepo) UnFollowUser(ctx context.Context, follower_id uint64, user_id uint64) error {
+	tx, err := UserRepo.db.BeginTx(ctx, nil)
+	if err != nil {
 		return err
 	}
-	return nil
-}
+	defer tx.Rollback()
 
-// UnFollowUser - remove a row in followers table
-func (UserRepo UserRepo) UnFollowUser(follower_id uint64, user_id uint64) error {
-	statement, err := UserRepo.db.Prepare(
+	result, err := tx.ExecContext(ctx,
 		"DELETE FROM followers WHERE user_id = ? and follower_id = ?",
+		user_id, follower_id,
 	)
 	if err != nil {
 		return err
 	}
-	defer statement.Close()
+	if err = updateFollowCounts(ctx, tx, result, follower_id, user_id, -1); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-	if _, err := statement.Exec(user_id, follower_id); err != nil {
+// updateFollowCounts - apply delta to the counters only when the follow
+// statement changed a row, INSERT IGNORE and DELETE can be no-ops. The
+// counters only count active users, so a side changes when the other one
+// is active. Both users are updated in one statement so concurrent follows
+// lock the rows in the same order.
+func updateFollowCounts(ctx context.Context, tx *sql.Tx, result sql.Result, follower_id uint64, user_id uint64, delta int) error {
+	changed, err := result.RowsAffected()
+	if err != nil || changed == 0 {
 		return err
 	}
-	return nil
+	var followerActive, userActive bool
+	if err = tx.QueryRowContext(ctx, `
+	   SELECT coalesce(sum(id = ?), 0) > 0, coalesce(sum(id = ?), 0) > 0
+	   FROM users WHERE id IN (?, ?) AND deactivatedAt IS NULL
+	`, follower_id, user_id, follower_id, user_id).Scan(&followerActive, &userActive); err != nil {
+		return err
+	}
+	var followerDelta, followingDelta int
+	if followerActive {
+		followerDelta = delta
+	}
+	if userActive {
+		followingDelta = delta
+	}
+	_, err = tx.ExecContext(ctx, `
+	   UPDATE users SET
+	      follower_count = follower_count + IF(id = ?, ?, 0),
+	      following_count = following_count + IF(id = ?, ?, 0)
+	   WHERE id IN (?, ?)
+	`, user_id, followerDelta, follower_id, followingDelta, user_id, follower_id)
+	return err
+}
+
+// ReconcileFollowCounts - recount the counters from the followers table,
+// only counting follows with active users, returns how many users had
+// drifted
+func (UserRepo UserRepo) ReconcileFollowCounts(ctx context.Context) (int64, error) {
+	result, err := UserRepo.db.ExecContext(ctx, `
+	   UPDATE users u
+	   INNER JOIN (
+	      SELECT c.id,
+	         (SELECT count(*) FROM followers f INNER JOIN users o ON (o.id = f.follower_id)
+	          WHERE f.user_id = c.id AND o.deactivatedAt IS NULL) AS followers,
+	         (SELECT count(*) FROM followers f INNER JOIN users o ON (o.id = f.user_id)
+	          WHERE f.follower_id = c.id AND o.deactivatedAt IS NULL) AS following
+	      FROM users c
+	   ) counts ON (counts.id = u.id)
+	   SET u.follower_count = counts.followers, u.following_count = counts.following
+	   WHERE u.follower_count <> counts.followers OR u.following_count <> counts.following
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 // GetFollowers - Get all followers from an user
-func (UserRepo UserRepo) GetFollowers(userID uint64) ([]models.User, error) {
-	rows, err := UserRepo.db.Query(`
-	   select u.id, u.name, u.nick, u.email, u.createAt
+func (UserRepo UserRepo) GetFollowers(ctx context.Context, userID uint64) ([]models.User, error) {
+	rows, err := UserRepo.db.QueryContext(ctx, `
+	   select u.id, u.name, u.nick, u.email, u.createAt, u.follower_count, u.following_count
 	   FROM users u INNER JOIN followers f ON (f.follower_id = u.id)
-	   WHERE f.user_id = ?
+	   WHERE f.user_id = ? AND u.deactivatedAt IS NULL
 	`, userID)
 	if err != nil {
-		return []models.User{}, nil
+		return []models.User{}, err
 	}
 	defer rows.Close()
 
@@ -206,23 +456,110 @@ func (UserRepo UserRepo) GetFollowers(userID uint64) ([]models.User, error) {
 			&user.Nick,
 			&user.Email,
 			&user.CreateAt,
+			&user.FollowerCount,
+			&user.FollowingCount,
 		); err != nil {
-			return []models.User{}, nil
+			return []models.User{}, err
 		}
 		users = append(users, user)
 	}
-	return users, nil
+	return users, rows.Err()
 }
 
 // GetFollowing - Get all users followed by user
-func (UserRepo UserRepo) GetFollowing(userID uint64) ([]models.User, error) {
-	rows, err := UserRepo.db.Query(`
-	   select u.id, u.name, u.nick, u.email, u.createAt
+func (UserRepo UserRepo) GetFollowing(ctx context.Context, userID uint64) ([]models.User, error) {
+	rows, err := UserRepo.db.QueryContext(ctx, `
+	   select u.id, u.name, u.nick, u.email, u.createAt, u.follower_count, u.following_count
 	   FROM users u INNER JOIN followers f ON (f.user_id = u.id)
-	   WHERE f.follower_id = ?
+	   WHERE f.follower_id = ? AND u.deactivatedAt IS NULL
 	`, userID)
 	if err != nil {
-		return []models.User{}, nil
+		return []models.User{}, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(
+			&user.ID,
+			&user.Name,
+			&user.Nick,
+			&user.Email,
+			&user.CreateAt,
+			&user.FollowerCount,
+			&user.FollowingCount,
+		); err != nil {
+			return []models.User{}, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// FindMessagingSettings - who may message an user
+func (UserRepo UserRepo) FindMessagingSettings(ctx context.Context, ID uint64) (models.MessagingSettings, error) {
+	var settings models.MessagingSettings
+	err := UserRepo.db.QueryRowContext(ctx,
+		"SELECT dmFollowingOnly FROM users WHERE id = ?", ID,
+	).Scan(&settings.FollowingOnly)
+	return settings, err
+}
+
+// UpdateMessagingSettings - change who may message an user
+func (UserRepo UserRepo) UpdateMessagingSettings(ctx context.Context, ID uint64, settings models.MessagingSettings) error {
+	_, err := UserRepo.db.ExecContext(ctx,
+		"UPDATE users SET dmFollowingOnly = ? WHERE id = ?", settings.FollowingOnly, ID,
+	)
+	return err
+}
+
+// Relationships - follow flags between an user and each of ids, active
+// users only
+func (UserRepo UserRepo) Relationships(ctx context.Context, userID uint64, ids []uint64) ([]models.Relationship, error) {
+	relationships := []models.Relationship{}
+	if len(ids) == 0 {
+		return relationships, nil
+	}
+	args := []interface{}{userID, userID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := UserRepo.db.QueryContext(ctx, `
+	   SELECT u.id,
+	      EXISTS (SELECT 1 FROM followers f WHERE f.user_id = u.id AND f.follower_id = ?),
+	      EXISTS (SELECT 1 FROM followers f WHERE f.user_id = ? AND f.follower_id = u.id)
+	   FROM users u
+	   WHERE u.id IN (?`+strings.Repeat(", ?", len(ids)-1)+`) AND u.deactivatedAt IS NULL
+	   ORDER BY u.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var relationship models.Relationship
+		if err = rows.Scan(&relationship.UserID, &relationship.Following, &relationship.FollowedBy); err != nil {
+			return nil, err
+		}
+		relationship.Mutual = relationship.Following && relationship.FollowedBy
+		relationships = append(relationships, relationship)
+	}
+	return relationships, rows.Err()
+}
+
+// GetMutuals - users that follow an user and are followed back
+func (UserRepo UserRepo) GetMutuals(ctx context.Context, userID uint64) ([]models.User, error) {
+	rows, err := UserRepo.db.QueryContext(ctx, `
+	   select u.id, u.name, u.nick, u.email, u.createAt, u.follower_count, u.following_count
+	   FROM users u
+	   INNER JOIN followers following ON (following.user_id = u.id AND following.follower_id = ?)
+	   INNER JOIN followers follower ON (follower.user_id = ? AND follower.follower_id = u.id)
+	   WHERE u.deactivatedAt IS NULL
+	`, userID, userID)
+	if err != nil {
+		return []models.User{}, err
 	}
 	defer rows.Close()
 
@@ -235,10 +572,12 @@ func (UserRepo UserRepo) GetFollowing(userID uint64) ([]models.User, error) {
 			&user.Nick,
 			&user.Email,
 			&user.CreateAt,
+			&user.FollowerCount,
+			&user.FollowingCount,
 		); err != nil {
-			return []models.User{}, nil
+			return []models.User{}, err
 		}
 		users = append(users, user)
 	}
-	return users, nil
+	return users, rows.Err()
 }
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"api/src/models"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDeleteAndRestoreCounters(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	userRepo := NewUserRepo(db)
+	IDs := createUsers(t, db, 3)
+	a, b, c := IDs[0], IDs[1], IDs[2]
+	// b follows a follows c follows b, a is on one of c's lists
+	follow(t, db, [2]uint64{b, a}, [2]uint64{a, c}, [2]uint64{c, b})
+	listID, err := NewListRepo(db).Create(ctx, models.List{OwnerID: c, Name: "friends"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewListRepo(db).AddMember(ctx, listID, a); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		apply       func() error
+		wantA       [2]int
+		wantB       [2]int
+		wantC       [2]int
+		wantMembers int
+	}{
+		{"delete", func() error { return userRepo.Delete(ctx, a) }, [2]int{1, 1}, [2]int{1, 0}, [2]int{0, 1}, 0},
+		{"delete again", func() error { return userRepo.Delete(ctx, a) }, [2]int{1, 1}, [2]int{1, 0}, [2]int{0, 1}, 0},
+		{"restore", func() error { return userRepo.Restore(ctx, a) }, [2]int{1, 1}, [2]int{1, 1}, [2]int{1, 1}, 1},
+		{"restore again", func() error { return userRepo.Restore(ctx, a) }, [2]int{1, 1}, [2]int{1, 1}, [2]int{1, 1}, 1},
+	}
+	for _, test := range tests {
+		if err := test.apply(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		for _, user := range []struct {
+			ID   uint64
+			want [2]int
+		}{{a, test.wantA}, {b, test.wantB}, {c, test.wantC}} {
+			if got := followCounts(t, db, user.ID); got != user.want {
+				t.Errorf("%s: user %d followers/following %v, want %v", test.name, user.ID, got, user.want)
+			}
+		}
+		if got := listCounts(t, db, listID)[0]; got != test.wantMembers {
+			t.Errorf("%s: member_count %d, want %d", test.name, got, test.wantMembers)
+		}
+		assertReconciled(t, db)
+	}
+}
+
+func TestRelationshipsAndMutuals(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	userRepo := NewUserRepo(db)
+	IDs := createUsers(t, db, 6)
+	me := IDs[0]
+	tests := []struct {
+		name         string
+		ID           uint64
+		following    bool
+		followedBy   bool
+		deleted      bool
+		wantFound    bool
+		wantRelation models.Relationship
+	}{
+		{"mutual", IDs[1], true, true, false, true, models.Relationship{UserID: IDs[1], Following: true, FollowedBy: true, Mutual: true}},
+		{"following", IDs[2], true, false, false, true, models.Relationship{UserID: IDs[2], Following: true}},
+		{"followed by", IDs[3], false, true, false, true, models.Relationship{UserID: IDs[3], FollowedBy: true}},
+		{"none", IDs[4], false, false, false, true, models.Relationship{UserID: IDs[4]}},
+		{"deleted mutual", IDs[5], true, true, true, false, models.Relationship{}},
+		{"missing", IDs[5] + 100, false, false, false, false, models.Relationship{}},
+	}
+	var ids, wantMutuals []uint64
+	for _, test := range tests {
+		ids = append(ids, test.ID)
+		if test.following {
+			follow(t, db, [2]uint64{me, test.ID})
+		}
+		if test.followedBy {
+			follow(t, db, [2]uint64{test.ID, me})
+		}
+		if test.deleted {
+			if err := userRepo.Delete(ctx, test.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if test.wantRelation.Mutual {
+			wantMutuals = append(wantMutuals, test.ID)
+		}
+	}
+
+	relationships, err := userRepo.Relationships(ctx, me, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := map[uint64]models.Relationship{}
+	for _, relationship := range relationships {
+		found[relationship.UserID] = relationship
+	}
+	for _, test := range tests {
+		relationship, ok := found[test.ID]
+		if ok != test.wantFound || relationship != test.wantRelation {
+			t.Errorf("%s: relationship %+v, found %v, want %+v, %v", test.name, relationship, ok, test.wantRelation, test.wantFound)
+		}
+	}
+
+	mutuals, err := userRepo.GetMutuals(ctx, me)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotMutuals []uint64
+	for _, user := range mutuals {
+		gotMutuals = append(gotMutuals, user.ID)
+	}
+	if !reflect.DeepEqual(gotMutuals, wantMutuals) {
+		t.Errorf("GetMutuals = %v, want %v", gotMutuals, wantMutuals)
+	}
+}
+
+func TestFollowCounters(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	userRepo := NewUserRepo(db)
+	IDs := createUsers(t, db, 3)
+	users := map[string]uint64{"a": IDs[0], "b": IDs[1], "c": IDs[2]}
+
+	followUser := func(follower, user string, wantFollowed bool) func() error {
+		return func() error {
+			followed, err := userRepo.FollowUser(ctx, users[follower], users[user])
+			if err == nil && followed != wantFollowed {
+				err = fmt.Errorf("FollowUser = %v, want %v", followed, wantFollowed)
+			}
+			return err
+		}
+	}
+	unfollowUser := func(follower, user string) func() error {
+		return func() error { return userRepo.UnFollowUser(ctx, users[follower], users[user]) }
+	}
+	purge := func(before time.Duration) func() error {
+		return func() error {
+			_, err := userRepo.Purge(ctx, time.Now().Add(before))
+			return err
+		}
+	}
+
+	tests := []struct {
+		name  string
+		apply func() error
+		// followers and following of each user still there
+		want map[string][2]int
+	}{
+		{"follow", followUser("a", "b", true), map[string][2]int{"a": {0, 1}, "b": {1, 0}, "c": {0, 0}}},
+		{"follow again", followUser("a", "b", true), map[string][2]int{"a": {0, 1}, "b": {1, 0}, "c": {0, 0}}},
+		{"follow back", followUser("b", "a", true), map[string][2]int{"a": {1, 1}, "b": {1, 1}, "c": {0, 0}}},
+		{"third follower", followUser("c", "a", true), map[string][2]int{"a": {2, 1}, "b": {1, 1}, "c": {0, 1}}},
+		{"deactivate", func() error { return userRepo.Delete(ctx, users["c"]) }, map[string][2]int{"a": {1, 1}, "b": {1, 1}, "c": {0, 1}}},
+		{"follow a deactivated user", followUser("a", "c", false), map[string][2]int{"a": {1, 1}, "b": {1, 1}, "c": {0, 1}}},
+		{"deactivated user unfollows", unfollowUser("c", "a"), map[string][2]int{"a": {1, 1}, "b": {1, 1}, "c": {0, 0}}},
+		{"deactivated user follows", followUser("c", "a", true), map[string][2]int{"a": {1, 1}, "b": {1, 1}, "c": {0, 1}}},
+		{"restore", func() error { return userRepo.Restore(ctx, users["c"]) }, map[string][2]int{"a": {2, 1}, "b": {1, 1}, "c": {0, 1}}},
+		{"unfollow", unfollowUser("b", "a"), map[string][2]int{"a": {1, 1}, "b": {1, 0}, "c": {0, 1}}},
+		{"unfollow again", unfollowUser("b", "a"), map[string][2]int{"a": {1, 1}, "b": {1, 0}, "c": {0, 1}}},
+		{"deactivate again", func() error { return userRepo.Delete(ctx, users["c"]) }, map[string][2]int{"a": {0, 1}, "b": {1, 0}, "c": {0, 1}}},
+		{"purge before the deactivation", purge(-time.Hour), map[string][2]int{"a": {0, 1}, "b": {1, 0}, "c": {0, 1}}},
+		{"purge", purge(time.Hour), map[string][2]int{"a": {0, 1}, "b": {1, 0}}},
+	}
+	for _, test := range tests {
+		if err := test.apply(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		for name, want := range test.want {
+			if got := followCounts(t, db, users[name]); got != want {
+				t.Errorf("%s: %s followers/following %v, want %v", test.name, name, got, want)
+			}
+		}
+		assertReconciled(t, db)
+	}
+	var remaining int
+	if err := db.QueryRow("SELECT count(*) FROM users WHERE id = ?", users["c"]).Scan(&remaining); err != nil || remaining != 0 {
+		t.Errorf("purged user still there: %d, %v", remaining, err)
+	}
+}
+
+func TestReconcileFollowCounts(t *testing.T) {
+	db := testDB(t)
+	IDs := createUsers(t, db, 2)
+	follow(t, db, [2]uint64{IDs[0], IDs[1]})
+
+	tests := []struct {
+		name        string
+		drift       string
+		wantDrifted int64
+	}{
+		{"in sync", "", 0},
+		{"one user drifted", "UPDATE users SET follower_count = 5 WHERE id = ?", 1},
+		{"both users drifted", "UPDATE users SET follower_count = 0, following_count = 3 WHERE id <= ?", 2},
+	}
+	for _, test := range tests {
+		if test.drift != "" {
+			if _, err := db.Exec(test.drift, IDs[1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		drifted, err := NewUserRepo(db).ReconcileFollowCounts(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if drifted != test.wantDrifted {
+			t.Errorf("%s: %d drifted, want %d", test.name, drifted, test.wantDrifted)
+		}
+		if got := [2][2]int{followCounts(t, db, IDs[0]), followCounts(t, db, IDs[1])}; got != [2][2]int{{0, 1}, {1, 0}} {
+			t.Errorf("%s: counters %v after reconciling", test.name, got)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"net/http"
+	"time"
+)
+
+// manageAdmin is never granted to access tokens, admin routes need a login
+const manageAdmin = "manage:admin"
+
+var auditRoutes = []Route{
+	{
+		URI:        "/audit",
+		Method:     http.MethodGet,
+		Controller: controllers.GetAuditLog,
+		Admin:      true,
+		Scope:      manageAdmin,
+	},
+	{
+		URI:        "/audit/export",
+		Method:     http.MethodGet,
+		Controller: controllers.ExportAuditLog,
+		Admin:      true,
+		Scope:      manageAdmin,
+		Timeout:    5 * time.Minute,
+	},
+}
@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"net/http"
+	"time"
+)
+
+// manageExports is never granted to access tokens, a personal data export
+// holds everything about the user
+const manageExports = "manage:exports"
+
+var exportRoutes = []Route{
+	{
+		URI:        "/users/{id}/export",
+		Method:     http.MethodPost,
+		Controller: controllers.CreateExport,
+		Scope:      manageExports,
+	},
+	{
+		URI:        "/users/{id}/exports/{exportID}",
+		Method:     http.MethodGet,
+		Controller: controllers.GetExport,
+		Scope:      manageExports,
+	},
+	{
+		// the signed link is the credential, browsers download it directly
+		URI:        "/exports/{id}/download",
+		Method:     http.MethodGet,
+		Controller: controllers.DownloadExport,
+		Timeout:    5 * time.Minute,
+	},
+}
@@ -0,0 +1,75 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"net/http"
+)
+
+var listRoutes = []Route{
+	{
+		URI:        "/lists",
+		Method:     http.MethodPost,
+		Controller: controllers.CreateList,
+		Scope:      "write:lists",
+	},
+	{
+		URI:        "/lists/{id}",
+		Method:     http.MethodGet,
+		Controller: controllers.GetList,
+		Scope:      "read:lists",
+	},
+	{
+		URI:        "/lists/{id}",
+		Method:     http.MethodPut,
+		Controller: controllers.UpdateList,
+		Scope:      "write:lists",
+	},
+	{
+		URI:        "/lists/{id}",
+		Method:     http.MethodDelete,
+		Controller: controllers.DeleteList,
+		Scope:      "write:lists",
+	},
+	{
+		URI:        "/lists/{id}/members",
+		Method:     http.MethodGet,
+		Controller: controllers.GetListMembers,
+		Scope:      "read:lists",
+	},
+	{
+		URI:        "/lists/{id}/members/{userID}",
+		Method:     http.MethodPut,
+		Controller: controllers.AddListMember,
+		Scope:      "write:lists",
+	},
+	{
+		URI:        "/lists/{id}/members/{userID}",
+		Method:     http.MethodDelete,
+		Controller: controllers.RemoveListMember,
+		Scope:      "write:lists",
+	},
+	{
+		URI:        "/lists/{id}/subscribe",
+		Method:     http.MethodPost,
+		Controller: controllers.SubscribeList,
+		Scope:      "write:lists",
+	},
+	{
+		URI:        "/lists/{id}/unsubscribe",
+		Method:     http.MethodDelete,
+		Controller: controllers.UnsubscribeList,
+		Scope:      "write:lists",
+	},
+	{
+		URI:        "/users/{id}/lists",
+		Method:     http.MethodGet,
+		Controller: controllers.GetUserLists,
+		Scope:      "read:lists",
+	},
+	{
+		URI:        "/users/{id}/lists/subscribed",
+		Method:     http.MethodGet,
+		Controller: controllers.GetListSubscriptions,
+		Scope:      "read:lists",
+	},
+}
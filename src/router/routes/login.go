@@ -3,6 +3,7 @@ package routes
 import (
 	"api/src/controllers"
 	"net/http"
+	"time"
 )
 
 var routerLogin = Route{
@@ -11,3 +12,38 @@ var routerLogin = Route{
 	Controller:     controllers.Login,
 	Authentication: false,
 }
+
+var loginRoutes = []Route{
+	routerLogin,
+	{
+		URI:            "/logout",
+		Method:         http.MethodPost,
+		Controller:     controllers.Logout,
+		Authentication: true,
+		Scope:          manageSessions,
+	},
+	{
+		URI:        "/login/lockouts/{email}",
+		Method:     http.MethodDelete,
+		Controller: controllers.UnlockLogin,
+		Admin:      true,
+		Scope:      manageAdmin,
+	},
+	{
+		URI:        "/login/oidc",
+		Method:     http.MethodGet,
+		Controller: controllers.OIDCLogin,
+	},
+	{
+		URI:        "/login/oidc/callback",
+		Method:     http.MethodGet,
+		Controller: controllers.OIDCCallback,
+		// waits on the provider token and JWKS endpoints
+		Timeout: 15 * time.Second,
+	},
+	{
+		URI:        "/.well-known/jwks.json",
+		Method:     http.MethodGet,
+		Controller: controllers.JWKS,
+	},
+}
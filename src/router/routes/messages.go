@@ -0,0 +1,61 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"api/src/ratelimit"
+	"net/http"
+	"time"
+)
+
+var messageRoutes = []Route{
+	{
+		URI:        "/conversations",
+		Method:     http.MethodPost,
+		Controller: controllers.CreateConversation,
+		Scope:      "write:messages",
+	},
+	{
+		URI:        "/conversations",
+		Method:     http.MethodGet,
+		Controller: controllers.GetConversations,
+		Scope:      "read:messages",
+	},
+	{
+		URI:        "/conversations/{id}/messages",
+		Method:     http.MethodGet,
+		Controller: controllers.GetMessages,
+		Scope:      "read:messages",
+	},
+	{
+		URI:        "/conversations/{id}/messages",
+		Method:     http.MethodPost,
+		Controller: controllers.SendMessage,
+		Scope:      "write:messages",
+		// keep a compromised or scripted account from flooding inboxes
+		RateLimit: ratelimit.Rule{Requests: 60, Window: time.Minute},
+	},
+	{
+		URI:        "/conversations/{id}/read",
+		Method:     http.MethodPost,
+		Controller: controllers.ReadConversation,
+		Scope:      "read:messages",
+	},
+	{
+		URI:        "/conversations/{id}/messages/{messageID}",
+		Method:     http.MethodDelete,
+		Controller: controllers.DeleteMessage,
+		Scope:      "write:messages",
+	},
+	{
+		URI:        "/users/{id}/settings/messaging",
+		Method:     http.MethodGet,
+		Controller: controllers.GetMessagingSettings,
+		Scope:      "read:messages",
+	},
+	{
+		URI:        "/users/{id}/settings/messaging",
+		Method:     http.MethodPut,
+		Controller: controllers.UpdateMessagingSettings,
+		Scope:      "write:messages",
+	},
+}
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"net/http"
+)
+
+// manageNotifications is never granted to access tokens, notifications
+// carry links such as personal data export downloads
+const manageNotifications = "manage:notifications"
+
+var notificationRoutes = []Route{
+	{
+		URI:            "/notifications",
+		Method:         http.MethodGet,
+		Controller:     controllers.GetNotifications,
+		Authentication: true,
+		Scope:          manageNotifications,
+	},
+	{
+		URI:            "/notifications/read",
+		Method:         http.MethodPost,
+		Controller:     controllers.ReadNotifications,
+		Authentication: true,
+		Scope:          manageNotifications,
+	},
+}
@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"net/http"
+)
+
+// manageOAuth is never granted to access tokens, only a login can register
+// clients or give consent
+const manageOAuth = "manage:oauth"
+
+var oauthRoutes = []Route{
+	{
+		URI:        "/oauth/clients",
+		Method:     http.MethodPost,
+		Controller: controllers.RegisterOAuthClient,
+		Scope:      manageOAuth,
+	},
+	{
+		URI:        "/oauth/clients",
+		Method:     http.MethodGet,
+		Controller: controllers.GetOAuthClients,
+		Scope:      manageOAuth,
+	},
+	{
+		URI:        "/oauth/clients/{id}",
+		Method:     http.MethodDelete,
+		Controller: controllers.DeleteOAuthClient,
+		Scope:      manageOAuth,
+	},
+	{
+		URI:        "/oauth/authorize",
+		Method:     http.MethodGet,
+		Controller: controllers.GetAuthorization,
+		Scope:      manageOAuth,
+	},
+	{
+		URI:        "/oauth/authorize",
+		Method:     http.MethodPost,
+		Controller: controllers.Authorize,
+		Scope:      manageOAuth,
+	},
+	{
+		URI:        "/oauth/token",
+		Method:     http.MethodPost,
+		Controller: controllers.OAuthToken,
+	},
+	{
+		URI:        "/oauth/introspect",
+		Method:     http.MethodPost,
+		Controller: controllers.IntrospectOAuthToken,
+	},
+	{
+		URI:        "/oauth/revoke",
+		Method:     http.MethodPost,
+		Controller: controllers.RevokeOAuthToken,
+	},
+}
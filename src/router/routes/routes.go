@@ -1,8 +1,12 @@
 package routes
 
 import (
+	"api/src/config"
 	"api/src/middlewares"
+	"api/src/ratelimit"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -13,22 +17,68 @@ type Route struct {
 	Method         string
 	Controller     func(http.ResponseWriter, *http.Request)
 	Authentication bool
+	// Admin - restrict the route to API_ADMINS
+	Admin bool
+	// Scope - access token scope the route needs, logins have every scope.
+	// Authenticated routes must set one, access tokens are refused otherwise.
+	Scope string
+	// Timeout - deadline for the request; zero uses config.QueryTimeout.
+	// ROUTE_TIMEOUTS entries override it.
+	Timeout time.Duration
+	// RateLimit - requests allowed per caller; zero uses the config defaults
+	RateLimit ratelimit.Rule
 }
 
 // ConfigRouters - join all routes configs
 func ConfigRouters(r *mux.Router) *mux.Router {
 	routes := userRoutes
-	routes = append(routes, routerLogin)
+	routes = append(routes, loginRoutes...)
+	routes = append(routes, tokenRoutes...)
+	routes = append(routes, sessionRoutes...)
+	routes = append(routes, oauthRoutes...)
+	routes = append(routes, auditRoutes...)
+	routes = append(routes, exportRoutes...)
+	routes = append(routes, notificationRoutes...)
+	routes = append(routes, messageRoutes...)
+	routes = append(routes, listRoutes...)
 
+	methods := map[string][]string{}
+	var uris []string
 	for _, router := range routes {
-		if router.Authentication {
-			r.HandleFunc(router.URI,
-				middlewares.Logger(
-					middlewares.Authentication(router.Controller),
-				)).Methods(router.Method)
-		} else {
-			r.HandleFunc(router.URI, middlewares.Logger(router.Controller)).Methods(router.Method)
+		if _, ok := methods[router.URI]; !ok {
+			uris = append(uris, router.URI)
 		}
+		methods[router.URI] = append(methods[router.URI], router.Method)
+	}
+	for _, uri := range uris {
+		allowed := append(methods[uri], http.MethodOptions)
+		r.HandleFunc(uri, middlewares.Logger(middlewares.Preflight(allowed))).Methods(http.MethodOptions)
+	}
+
+	for _, router := range routes {
+		controller := router.Controller
+		if router.Authentication || router.Admin || router.Scope != "" {
+			if router.Scope == "" {
+				log.Fatalf("routes: %s %s is authenticated but has no scope", router.Method, router.URI)
+			}
+			controller = middlewares.Scope(router.Scope, controller)
+			if router.Admin {
+				controller = middlewares.Admin(controller)
+			}
+			controller = middlewares.Authentication(controller)
+			// public routes like /login and /oauth/token don't read the
+			// cookie, there is nothing to forge there
+			controller = middlewares.CSRF(controller)
+		}
+		timeout := router.Timeout
+		if override, ok := config.RouteTimeouts[router.Method+" "+router.URI]; ok {
+			timeout = override
+		}
+		controller = middlewares.RateLimit(router.Method+" "+router.URI, router.RateLimit, controller)
+		controller = middlewares.Timeout(timeout, controller)
+		controller = middlewares.CORS(controller)
+
+		r.HandleFunc(router.URI, middlewares.Logger(controller)).Methods(router.Method)
 	}
 	return r
 }
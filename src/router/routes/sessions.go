@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"net/http"
+)
+
+// manageSessions is never granted to access tokens
+const manageSessions = "manage:sessions"
+
+var sessionRoutes = []Route{
+	{
+		URI:        "/sessions",
+		Method:     http.MethodGet,
+		Controller: controllers.GetSessions,
+		Scope:      manageSessions,
+	},
+	{
+		URI:        "/sessions",
+		Method:     http.MethodDelete,
+		Controller: controllers.DeleteSessions,
+		Scope:      manageSessions,
+	},
+	{
+		URI:        "/sessions/{id}",
+		Method:     http.MethodDelete,
+		Controller: controllers.DeleteSession,
+		Scope:      manageSessions,
+	},
+}
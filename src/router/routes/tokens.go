@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"api/src/controllers"
+	"net/http"
+)
+
+// manageTokens is never granted to access tokens, so only a login can
+// create, list or revoke them
+const manageTokens = "manage:tokens"
+
+var tokenRoutes = []Route{
+	{
+		URI:        "/tokens",
+		Method:     http.MethodPost,
+		Controller: controllers.CreateAccessToken,
+		Scope:      manageTokens,
+	},
+	{
+		URI:        "/tokens",
+		Method:     http.MethodGet,
+		Controller: controllers.GetAccessTokens,
+		Scope:      manageTokens,
+	},
+	{
+		URI:        "/tokens/{id}",
+		Method:     http.MethodDelete,
+		Controller: controllers.DeleteAccessToken,
+		Scope:      manageTokens,
+	},
+}
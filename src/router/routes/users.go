@@ -2,9 +2,15 @@ package routes
 
 import (
 	"api/src/controllers"
+	"api/src/ratelimit"
 	"net/http"
+	"time"
 )
 
+// manageAccount is never granted to access tokens, changing or deleting
+// the account takes a login
+const manageAccount = "manage:account"
+
 var userRoutes = []Route{
 	{
 		URI:            "/users",
@@ -17,47 +23,93 @@ var userRoutes = []Route{
 		Method:         http.MethodGet,
 		Controller:     controllers.GetUsers,
 		Authentication: true,
+		Scope:          "read:users",
+		// name/nick search is a LIKE scan, keep it cheap
+		RateLimit: ratelimit.Rule{Requests: 30, Window: time.Minute},
+	},
+	{
+		// before /users/{id}, which would match it first
+		URI:            "/users/relationships",
+		Method:         http.MethodGet,
+		Controller:     controllers.GetRelationships,
+		Authentication: true,
+		Scope:          "read:follows",
 	},
 	{
 		URI:            "/users/{id}",
 		Method:         http.MethodGet,
 		Controller:     controllers.GetUser,
 		Authentication: true,
+		Scope:          "read:users",
 	},
 	{
 		URI:            "/users/{id}",
 		Method:         http.MethodDelete,
 		Controller:     controllers.DeleteUser,
 		Authentication: true,
+		Scope:          manageAccount,
 	},
 	{
 		URI:            "/users/{id}",
 		Method:         http.MethodPut,
 		Controller:     controllers.UpdateUser,
 		Authentication: true,
+		Scope:          manageAccount,
+	},
+	{
+		URI:            "/users/{id}/password",
+		Method:         http.MethodPut,
+		Controller:     controllers.UpdatePassword,
+		Authentication: true,
+		Scope:          manageAccount,
 	},
 	{
 		URI:            "/users/{id}/follow",
 		Method:         http.MethodPost,
 		Controller:     controllers.FollowUser,
 		Authentication: true,
+		Scope:          "write:follows",
 	},
 	{
 		URI:            "/users/{id}/unfollow",
 		Method:         http.MethodDelete,
 		Controller:     controllers.UnFollowUser,
 		Authentication: true,
+		Scope:          "write:follows",
 	},
 	{
 		URI:            "/users/{id}/followers",
 		Method:         http.MethodGet,
 		Controller:     controllers.GetFollowers,
 		Authentication: true,
+		Scope:          "read:follows",
 	},
 	{
 		URI:            "/users/{id}/following",
 		Method:         http.MethodGet,
 		Controller:     controllers.GetFollowing,
 		Authentication: true,
+		Scope:          "read:follows",
+	},
+	{
+		URI:            "/users/{id}/suggestions",
+		Method:         http.MethodGet,
+		Controller:     controllers.GetSuggestions,
+		Authentication: true,
+		Scope:          "read:follows",
+	},
+	{
+		URI:            "/users/{id}/relationship",
+		Method:         http.MethodGet,
+		Controller:     controllers.GetRelationship,
+		Authentication: true,
+		Scope:          "read:follows",
+	},
+	{
+		URI:            "/users/{id}/mutuals",
+		Method:         http.MethodGet,
+		Controller:     controllers.GetMutuals,
+		Authentication: true,
+		Scope:          "read:follows",
 	},
 }
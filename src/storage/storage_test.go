@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDisk(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	disk := NewDisk(dir)
+
+	if err := disk.Put(ctx, "1/a.zip", strings.NewReader("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := disk.Put(ctx, "1/a.zip", strings.NewReader("second")); err != nil {
+		t.Fatal(err)
+	}
+	file, err := disk.Open(ctx, "1/a.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadAll(file)
+	file.Close()
+	if string(content) != "second" {
+		t.Errorf("content = %q, want the last Put", content)
+	}
+
+	// nothing left behind by the write-then-rename
+	entries, _ := os.ReadDir(filepath.Join(dir, "1"))
+	if len(entries) != 1 {
+		t.Errorf("%d files in the key's directory, want 1", len(entries))
+	}
+
+	if err = disk.Delete(ctx, "1/a.zip"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = disk.Open(ctx, "1/a.zip"); !os.IsNotExist(err) {
+		t.Errorf("Open after Delete = %v, want not exist", err)
+	}
+	if err = disk.Delete(ctx, "1/a.zip"); err != nil {
+		t.Errorf("deleting a missing key = %v, want nil", err)
+	}
+}
+
+func TestDiskRejectsKeys(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	disk := NewDisk(filepath.Join(root, "exports"))
+	tests := []string{"", "/", "../outside.zip", "1/../../outside.zip", "1/.."}
+	for _, key := range tests {
+		if err := disk.Put(ctx, key, strings.NewReader("x")); err == nil {
+			t.Errorf("Put(%q) accepted", key)
+		}
+		if _, err := disk.Open(ctx, key); err == nil {
+			t.Errorf("Open(%q) accepted", key)
+		}
+		if err := disk.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q) accepted", key)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "outside.zip")); !os.IsNotExist(err) {
+		t.Error("a key escaped the store's directory")
+	}
+}
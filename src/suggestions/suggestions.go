@@ -0,0 +1,54 @@
+package suggestions
+
+import (
+	"api/src/config"
+	"api/src/models"
+	"api/src/repository"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// activeWindow - candidates seen this recently get a small boost
+const activeWindow = 7 * 24 * time.Hour
+
+// For - the best suggestions for an user, served from the cache while it
+// is younger than SUGGESTIONS_TTL so large graphs aren't walked per request
+func For(ctx context.Context, db *sql.DB, userID uint64, limit int) ([]models.Suggestion, error) {
+	repo := repository.NewSuggestionRepo(db)
+	suggestions, cached, err := repo.FindCached(ctx, userID, time.Now().Add(-config.SuggestionsTTL), limit)
+	if err != nil || cached {
+		return suggestions, err
+	}
+	if suggestions, err = Refresh(ctx, db, userID); err != nil {
+		return nil, err
+	}
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
+
+// Refresh - recompute and cache an user's suggestions
+func Refresh(ctx context.Context, db *sql.DB, userID uint64) ([]models.Suggestion, error) {
+	repo := repository.NewSuggestionRepo(db)
+	now := time.Now()
+	suggestions, err := repo.Compute(ctx, userID, now.Add(-activeWindow), config.SuggestionsCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	return suggestions, repo.Store(ctx, userID, now, suggestions)
+}
+
+// Due - active users whose cache expired, then those without one, at most
+// limit
+func Due(ctx context.Context, db *sql.DB, limit int) ([]uint64, error) {
+	now := time.Now()
+	return repository.NewSuggestionRepo(db).Stale(ctx, now.Add(-config.SuggestionsTTL), now.Add(-activeWindow), limit)
+}
+
+// Prune - drop the caches of users who haven't been active lately, there
+// is no point keeping them fresh
+func Prune(ctx context.Context, db *sql.DB) (int64, error) {
+	return repository.NewSuggestionRepo(db).DeleteInactive(ctx, time.Now().Add(-activeWindow))
+}
@@ -0,0 +1,83 @@
+package throttle
+
+import (
+	"api/src/models"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore - process-local Store, fine for a single API instance
+type MemoryStore struct {
+	mu        sync.Mutex
+	attempts  map[string]models.LoginAttempt
+	lastPrune time.Time
+}
+
+// NewMemoryStore - create an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{attempts: map[string]models.LoginAttempt{}}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt, ok := s.attempts[key]
+	if !ok {
+		return models.LoginAttempt{Key: key}, nil
+	}
+	return attempt, nil
+}
+
+func (s *MemoryStore) Fail(ctx context.Context, key string, now time.Time, maxAttempts int, lockout time.Duration) (models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(now, lockout)
+
+	attempt, ok := s.attempts[key]
+	if !ok {
+		attempt = models.LoginAttempt{Key: key}
+	}
+	attempt = Next(attempt, now, maxAttempts, lockout)
+	s.attempts[key] = attempt
+	return attempt, nil
+}
+
+func (s *MemoryStore) Refund(ctx context.Context, key string, maxAttempts int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempt, ok := s.attempts[key]
+	if !ok {
+		return nil
+	}
+	if attempt.Failures--; attempt.Failures <= 0 {
+		delete(s.attempts, key)
+		return nil
+	}
+	if maxAttempts <= 0 || attempt.Failures < maxAttempts {
+		attempt.LockedUntil = time.Time{}
+	}
+	s.attempts[key] = attempt
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
+
+// prune - forget expired attempts, at most once a minute, so keys for
+// every IP and mistyped email don't pile up
+func (s *MemoryStore) prune(now time.Time, lockout time.Duration) {
+	if now.Sub(s.lastPrune) < time.Minute {
+		return
+	}
+	s.lastPrune = now
+	for key, attempt := range s.attempts {
+		if Expired(attempt, now, lockout) {
+			delete(s.attempts, key)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package throttle
+
+import (
+	"api/src/config"
+	"api/src/models"
+	"context"
+	"strings"
+	"time"
+)
+
+// Store - where failed attempts are kept, in memory or in SQL
+type Store interface {
+	Get(ctx context.Context, key string) (models.LoginAttempt, error)
+	// Fail - count a failure for key in one atomic step, so parallel
+	// guesses can't all read the same count. See Next for the rules.
+	Fail(ctx context.Context, key string, now time.Time, maxAttempts int, lockout time.Duration) (models.LoginAttempt, error)
+	// Refund - take back one failure for key, lifting the lockout when
+	// the count drops below maxAttempts
+	Refund(ctx context.Context, key string, maxAttempts int) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Throttler - track failed logins per email and per client IP
+type Throttler struct {
+	store Store
+	now   func() time.Time
+}
+
+// New - create a throttler backed by store
+func New(store Store) *Throttler {
+	return &Throttler{store: store, now: time.Now}
+}
+
+// EmailKey - store key for an account
+func EmailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// IPKey - store key for a client address
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check - how long the caller must wait before trying again; zero means
+// the attempt may proceed
+func (t *Throttler) Check(ctx context.Context, email, ip string) (time.Duration, error) {
+	var wait time.Duration
+	for _, key := range []string{EmailKey(email), IPKey(ip)} {
+		attempt, err := t.store.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if w := t.retryAfter(attempt); w > wait {
+			wait = w
+		}
+	}
+	return wait, nil
+}
+
+// Fail - record a failed attempt and return how long the caller must wait
+// before the next one. locked names the keys this failure locked out.
+func (t *Throttler) Fail(ctx context.Context, email, ip string) (wait time.Duration, locked []string, err error) {
+	reservation, err := t.Reserve(ctx, email, ip)
+	return reservation.Wait, reservation.Locked, err
+}
+
+// Reservation - a login attempt counted as failed before the password is
+// checked, so parallel guesses can't all pass Check on the same count
+type Reservation struct {
+	// Allowed - whether the attempt is within the limits, the ones past
+	// them must be refused without checking the password
+	Allowed bool
+	// Wait - how long the caller must wait before the next attempt
+	Wait time.Duration
+	// Locked - the keys this attempt locked out
+	Locked []string
+}
+
+// Reserve - count an attempt as failed up front; Success takes it back
+// when the password turns out to be right
+func (t *Throttler) Reserve(ctx context.Context, email, ip string) (Reservation, error) {
+	emailAttempt, err := t.fail(ctx, EmailKey(email), config.LoginMaxAttempts)
+	if err != nil {
+		return Reservation{}, err
+	}
+	ipAttempt, err := t.fail(ctx, IPKey(ip), config.LoginMaxAttemptsIP)
+	if err != nil {
+		return Reservation{}, err
+	}
+	reservation := Reservation{
+		Allowed: within(emailAttempt, config.LoginMaxAttempts) && within(ipAttempt, config.LoginMaxAttemptsIP),
+	}
+	if emailAttempt.Failures == config.LoginMaxAttempts {
+		reservation.Locked = append(reservation.Locked, emailAttempt.Key)
+	}
+	if ipAttempt.Failures == config.LoginMaxAttemptsIP {
+		reservation.Locked = append(reservation.Locked, ipAttempt.Key)
+	}
+
+	reservation.Wait = t.retryAfter(emailAttempt)
+	if w := t.retryAfter(ipAttempt); w > reservation.Wait {
+		reservation.Wait = w
+	}
+	return reservation, nil
+}
+
+// within - whether the attempt that brought the count to attempt.Failures
+// may still be checked; the one reaching maxAttempts is the last
+func within(attempt models.LoginAttempt, maxAttempts int) bool {
+	return maxAttempts <= 0 || attempt.Failures <= maxAttempts
+}
+
+// Success - a correct password clears the account's failures and takes
+// back the IP's reserved one. The IP's earlier failures are kept so one
+// valid account can't reset a guessing run.
+func (t *Throttler) Success(ctx context.Context, email, ip string) error {
+	if err := t.store.Delete(ctx, EmailKey(email)); err != nil {
+		return err
+	}
+	return t.store.Refund(ctx, IPKey(ip), config.LoginMaxAttemptsIP)
+}
+
+// Unlock - clear failures and any lockout for an account
+func (t *Throttler) Unlock(ctx context.Context, email string) error {
+	return t.store.Delete(ctx, EmailKey(email))
+}
+
+func (t *Throttler) fail(ctx context.Context, key string, maxAttempts int) (models.LoginAttempt, error) {
+	return t.store.Fail(ctx, key, t.now(), maxAttempts, config.LoginLockout)
+}
+
+// Next - attempt after one more failure at now. A lockout that has run its
+// course, or failures quieter than lockout, start the count over; reaching
+// maxAttempts locks the key for lockout.
+func Next(attempt models.LoginAttempt, now time.Time, maxAttempts int, lockout time.Duration) models.LoginAttempt {
+	if Expired(attempt, now, lockout) {
+		attempt = models.LoginAttempt{Key: attempt.Key}
+	}
+	attempt.Failures++
+	attempt.LastFailure = now
+	if maxAttempts > 0 && attempt.Failures >= maxAttempts {
+		attempt.LockedUntil = now.Add(lockout)
+	}
+	return attempt
+}
+
+// Expired - whether an attempt no longer counts at now
+func Expired(attempt models.LoginAttempt, now time.Time, lockout time.Duration) bool {
+	if !attempt.LockedUntil.IsZero() {
+		return now.After(attempt.LockedUntil)
+	}
+	return now.Sub(attempt.LastFailure) > lockout
+}
+
+// retryAfter - remaining lockout, or the exponential backoff since the
+// last failure (base, 2*base, 4*base... capped at LoginBackoffMax)
+func (t *Throttler) retryAfter(attempt models.LoginAttempt) time.Duration {
+	now := t.now()
+	if attempt.LockedUntil.After(now) {
+		return attempt.LockedUntil.Sub(now)
+	}
+	if attempt.Failures == 0 || !attempt.LockedUntil.IsZero() {
+		return 0
+	}
+	backoff := config.LoginBackoffBase
+	for i := 1; i < attempt.Failures && backoff < config.LoginBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > config.LoginBackoffMax {
+		backoff = config.LoginBackoffMax
+	}
+	if wait := attempt.LastFailure.Add(backoff).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
@@ -0,0 +1,205 @@
+package throttle
+
+import (
+	"api/src/config"
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testThrottler(t *testing.T) (*Throttler, *time.Time) {
+	t.Helper()
+	maxAttempts, maxAttemptsIP, lockout := config.LoginMaxAttempts, config.LoginMaxAttemptsIP, config.LoginLockout
+	base, max := config.LoginBackoffBase, config.LoginBackoffMax
+	t.Cleanup(func() {
+		config.LoginMaxAttempts, config.LoginMaxAttemptsIP, config.LoginLockout = maxAttempts, maxAttemptsIP, lockout
+		config.LoginBackoffBase, config.LoginBackoffMax = base, max
+	})
+	config.LoginMaxAttempts, config.LoginMaxAttemptsIP, config.LoginLockout = 5, 20, 15*time.Minute
+	config.LoginBackoffBase, config.LoginBackoffMax = time.Second, 30*time.Second
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	throttler := New(NewMemoryStore())
+	throttler.now = func() time.Time { return now }
+	return throttler, &now
+}
+
+func TestFailBackoffAndLockout(t *testing.T) {
+	throttler, _ := testThrottler(t)
+	ctx := context.Background()
+	tests := []struct {
+		failure    int
+		wantWait   time.Duration
+		wantLocked bool
+	}{
+		{1, time.Second, false},
+		{2, 2 * time.Second, false},
+		{3, 4 * time.Second, false},
+		{4, 8 * time.Second, false},
+		{5, 15 * time.Minute, true},
+		{6, 15 * time.Minute, false},
+	}
+	for _, test := range tests {
+		wait, locked, err := throttler.Fail(ctx, "Someone@Example.com", "10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait != test.wantWait {
+			t.Errorf("failure %d: wait %v, want %v", test.failure, wait, test.wantWait)
+		}
+		if gotLocked := len(locked) == 1 && locked[0] == EmailKey("someone@example.com"); gotLocked != test.wantLocked {
+			t.Errorf("failure %d: locked %v, want email locked %v", test.failure, locked, test.wantLocked)
+		}
+	}
+	if wait, _ := throttler.Check(ctx, "someone@example.com", "10.0.0.2"); wait != 15*time.Minute {
+		t.Errorf("Check from another IP waits %v, want the account lockout", wait)
+	}
+}
+
+func TestBackoffCapped(t *testing.T) {
+	throttler, _ := testThrottler(t)
+	config.LoginMaxAttempts = 0
+	var wait time.Duration
+	for i := 0; i < 10; i++ {
+		wait, _, _ = throttler.Fail(context.Background(), "a@example.com", "10.0.0.1")
+	}
+	if wait != config.LoginBackoffMax {
+		t.Errorf("wait %v, want the %v cap", wait, config.LoginBackoffMax)
+	}
+}
+
+func TestCountStartsOver(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		after     time.Duration
+		wantCount int
+	}{
+		{"recent failures count", 3, time.Minute, 4},
+		{"quiet for the lockout", 3, 16 * time.Minute, 1},
+		{"during the lockout", 5, 10 * time.Minute, 6},
+		{"after the lockout", 5, 16 * time.Minute, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			throttler, now := testThrottler(t)
+			ctx := context.Background()
+			for i := 0; i < test.failures; i++ {
+				throttler.Fail(ctx, "a@example.com", "10.0.0.1")
+			}
+			*now = now.Add(test.after)
+			throttler.Fail(ctx, "a@example.com", "10.0.0.1")
+			attempt, _ := throttler.store.Get(ctx, EmailKey("a@example.com"))
+			if attempt.Failures != test.wantCount {
+				t.Errorf("failures %d, want %d", attempt.Failures, test.wantCount)
+			}
+		})
+	}
+}
+
+func TestSuccessKeepsIPFailures(t *testing.T) {
+	throttler, _ := testThrottler(t)
+	ctx := context.Background()
+	throttler.Fail(ctx, "a@example.com", "10.0.0.1")
+	if _, err := throttler.Reserve(ctx, "a@example.com", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := throttler.Success(ctx, "a@example.com", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	email, _ := throttler.store.Get(ctx, EmailKey("a@example.com"))
+	ip, _ := throttler.store.Get(ctx, IPKey("10.0.0.1"))
+	if email.Failures != 0 || ip.Failures != 1 {
+		t.Errorf("email failures %d, ip failures %d; want 0 and 1", email.Failures, ip.Failures)
+	}
+}
+
+func TestParallelFailuresAllCount(t *testing.T) {
+	throttler, _ := testThrottler(t)
+	config.LoginMaxAttempts = 0
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			throttler.Fail(ctx, "a@example.com", "10.0.0.1")
+		}()
+	}
+	wg.Wait()
+	attempt, _ := throttler.store.Get(ctx, EmailKey("a@example.com"))
+	if attempt.Failures != 50 {
+		t.Errorf("failures %d, want 50", attempt.Failures)
+	}
+}
+
+func TestParallelReservationsStopAtTheLimit(t *testing.T) {
+	throttler, _ := testThrottler(t)
+	ctx := context.Background()
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reservation, err := throttler.Reserve(ctx, "a@example.com", "10.0.0.1")
+			if err == nil && reservation.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != config.LoginMaxAttempts {
+		t.Errorf("%d attempts allowed, want %d", allowed, config.LoginMaxAttempts)
+	}
+}
+
+func TestRefund(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   int
+		wantCount  int
+		wantLocked bool
+	}{
+		{"no failures", 0, 0, false},
+		{"one failure", 1, 0, false},
+		{"below the limit", 3, 2, false},
+		{"reserved the last attempt", 5, 4, false},
+		{"past the limit", 6, 5, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			ctx := context.Background()
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i := 0; i < test.failures; i++ {
+				store.Fail(ctx, "email:a", now, 5, 15*time.Minute)
+			}
+			if err := store.Refund(ctx, "email:a", 5); err != nil {
+				t.Fatal(err)
+			}
+			attempt, _ := store.Get(ctx, "email:a")
+			if attempt.Failures != test.wantCount || attempt.LockedUntil.IsZero() == test.wantLocked {
+				t.Errorf("failures %d locked until %v, want %d and locked %v",
+					attempt.Failures, attempt.LockedUntil, test.wantCount, test.wantLocked)
+			}
+		})
+	}
+}
+
+func TestMemoryStorePrunes(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, key := range []string{"ip:1", "ip:2", "email:a"} {
+		store.Fail(ctx, key, start, 5, 15*time.Minute)
+	}
+	store.Fail(ctx, "ip:3", start.Add(20*time.Minute), 5, 15*time.Minute)
+	if len(store.attempts) != 1 {
+		t.Errorf("%d keys kept, want only the fresh one", len(store.attempts))
+	}
+}
@@ -1,7 +1,9 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 )
@@ -17,8 +19,15 @@ func JSON(w http.ResponseWriter, statusCode int, data interface{}) {
 	}
 }
 
-// Error return an error in json
+// Error return an error in json. Context errors take precedence over
+// statusCode: a hit deadline is a 504 and a cancelled request a 503.
 func Error(w http.ResponseWriter, statusCode int, error error) {
+	switch {
+	case errors.Is(error, context.DeadlineExceeded):
+		statusCode = http.StatusGatewayTimeout
+	case errors.Is(error, context.Canceled):
+		statusCode = http.StatusServiceUnavailable
+	}
 	JSON(w, statusCode, struct {
 		Error string `json:"error"`
 	}{
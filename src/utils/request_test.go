@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"api/src/config"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	defer func(proxies []*net.IPNet) { config.TrustedProxies = proxies }(config.TrustedProxies)
+	_, private, _ := net.ParseCIDR("10.0.0.0/8")
+	config.TrustedProxies = []*net.IPNet{private}
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		want      string
+	}{
+		{"direct", "203.0.113.9:1234", "", "203.0.113.9"},
+		{"direct with spoofed header", "203.0.113.9:1234", "198.51.100.1", "203.0.113.9"},
+		{"one proxy", "10.0.0.1:1234", "203.0.113.9", "203.0.113.9"},
+		{"client prepends a spoofed hop", "10.0.0.1:1234", "198.51.100.1, 203.0.113.9", "203.0.113.9"},
+		{"two proxies", "10.0.0.1:1234", "203.0.113.9, 10.0.0.2", "203.0.113.9"},
+		{"garbage left of the client", "10.0.0.1:1234", "not-an-ip, 203.0.113.9", "203.0.113.9"},
+		{"garbage as the client", "10.0.0.1:1234", "10.0.0.2, not-an-ip", "10.0.0.1"},
+		{"only proxies", "10.0.0.1:1234", "10.0.0.3, 10.0.0.2", "10.0.0.3"},
+		{"proxy without header", "10.0.0.1:1234", "", "10.0.0.1"},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.RemoteAddr = test.remote
+		if test.forwarded != "" {
+			request.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		if got := ClientIP(request); got != test.want {
+			t.Errorf("%s: ClientIP = %q, want %q", test.name, got, test.want)
+		}
+	}
+}